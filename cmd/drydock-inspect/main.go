@@ -0,0 +1,98 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Command drydock-inspect prints the state of the live actors of a drydock program serving the
+// debug endpoint (see runtime/turns/debug).
+//
+// Usage:
+//
+//	drydock-inspect [-addr=localhost:6061 | -addr=unix:/path/to/socket] [-json]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/debug"
+)
+
+var (
+	addr = flag.String("addr", debug.DefaultAddr,
+		"address of the debug endpoint (host:port or unix:<path>)")
+	rawJSON = flag.Bool("json", false, "print the raw JSON state")
+	timeout = flag.Duration("timeout", 10*time.Second, "time to wait for the endpoint to respond")
+)
+
+func main() {
+	flag.Parse()
+
+	actors, err := debug.Fetch(*addr, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "drydock-inspect: %v\n", err)
+		os.Exit(1)
+	}
+	if *rawJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(actors); err != nil {
+			fmt.Fprintf(os.Stderr, "drydock-inspect: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(actors) == 0 {
+		fmt.Println("No registered actors.")
+		return
+	}
+	for i, a := range actors {
+		if i > 0 {
+			fmt.Println()
+		}
+		printActor(os.Stdout, a)
+	}
+}
+
+// printActor pretty-prints the state of a single actor.
+func printActor(w io.Writer, a debug.ActorState) {
+	fmt.Fprintf(w, "actor %s\n", a.Name)
+	if a.State == nil {
+		fmt.Fprintf(w, "  error: %s\n", a.Error)
+		return
+	}
+	s := a.State
+	fmt.Fprintf(w, "  queued turns:       %d\n", s.Queued)
+	fmt.Fprintf(w, "  queue:              %s\n", s.Queue)
+	fmt.Fprintf(w, "  I/O backlog:        %d\n", s.Backlog)
+	if s.Sources != nil {
+		fmt.Fprintf(w, "  sources:            %s\n", strings.Join(s.Sources, ", "))
+	}
+	fmt.Fprintf(w, "  pending results:    %d\n", s.PendingResults)
+	if s.UnresolvedResults >= 0 {
+		fmt.Fprintf(w, "  unresolved results: %d\n", s.UnresolvedResults)
+	}
+	fmt.Fprintf(w, "  loops:              %d (%d turns, %d I/O turns, longest %v)\n", s.Stats.Loops,
+		s.Stats.Turns, s.Stats.IOTurns, s.Stats.MaxLoopDuration)
+	if len(s.RecentEvents) > 0 {
+		fmt.Fprintf(w, "  recent events:\n")
+		for _, e := range s.RecentEvents {
+			fmt.Fprintf(w, "    %s\n", e)
+		}
+	}
+}
@@ -16,18 +16,72 @@
 // Package assert contains static methods for expression invariant conditions in code.
 package assert
 
-import log "github.com/golang/glog"
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/golang/glog"
+)
 
 // True asserts an invariant must be true.  Panics if the condition is false.
 func True(condition bool, format string, a ...interface{}) {
 	if !condition {
-		log.Fatalf("precondition failed: "+format, a...)
+		fail(format, a...)
 	}
 }
 
 // False asserts an invariant must be false.  Panics if the condition is true.
 func False(condition bool, format string, a ...interface{}) {
 	if condition {
-		log.Fatalf("precondition failed: "+format, a...)
+		fail(format, a...)
+	}
+}
+
+// AddFailureHook registers f to be called with the failure message when an assertion fails, just
+// before the process exits.  Hooks are called in the order they were added and can be used to
+// record post-mortem context.  The returned func removes the hook.
+// THREADING: This method is multi-thread safe.
+func AddFailureHook(f func(message string)) (remove func()) {
+	hooksLock.Lock()
+	defer hooksLock.Unlock()
+	hookSeq++
+	id := hookSeq
+	hooks = append(hooks, failureHook{id, f})
+	return func() {
+		hooksLock.Lock()
+		defer hooksLock.Unlock()
+		for i, h := range hooks {
+			if h.id == id {
+				hooks = append(hooks[:i:i], hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// failureHook is a func registered by AddFailureHook.
+type failureHook struct {
+	id int
+	f  func(message string)
+}
+
+// hooks are the registered failure hooks in the order they were added.
+var hooks []failureHook
+
+// hookSeq is the id of the last hook added.
+var hookSeq int
+
+// hooksLock protects hooks and hookSeq.
+var hooksLock sync.Mutex
+
+// fail runs the failure hooks and then exits the process with the failure message.
+func fail(format string, a ...interface{}) {
+	message := fmt.Sprintf("precondition failed: "+format, a...)
+	hooksLock.Lock()
+	current := hooks
+	hooksLock.Unlock()
+	for _, h := range current {
+		h.f(message)
 	}
+	log.Fatal(message)
 }
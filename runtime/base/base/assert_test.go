@@ -16,6 +16,10 @@
 package base
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/prolang/drydock/runtime/base/assert"
@@ -37,3 +41,42 @@ func (t *AssertSuite) Coverage() {
 	assert.True(true, "Code coverage for assert.True()")
 	assert.False(false, "Code coverage for assert.False()")
 }
+
+// FailureHook verifies that failure hooks can be added and removed without being called.
+func (t *AssertSuite) FailureHook() {
+	called := false
+	remove := assert.AddFailureHook(func(string) {
+		called = true
+	})
+	assert.True(true, "Passing assertions don't call hooks.")
+	remove()
+	if called {
+		t.Errorf("Expected hook not to be called.  Got: %v, Want: %v", called, false)
+	}
+}
+
+// failureHookChild is set in the environment of a subprocess that fails an assertion.
+const failureHookChild = "DRYDOCK_TEST_ASSERT_FAILURE"
+
+// FailureHookCalled verifies that a failing assertion calls the failure hooks with its message
+// before the process exits.  The failure exits the process so it is run in a subprocess.
+func (t *AssertSuite) FailureHookCalled() {
+	if os.Getenv(failureHookChild) != "" {
+		assert.AddFailureHook(func(message string) {
+			fmt.Fprintf(os.Stderr, "hook called: %s\n", message)
+		})
+		assert.True(false, "deliberate failure")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestAssertSuite$")
+	cmd.Env = append(os.Environ(), failureHookChild+"=1")
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Errorf("Expected the subprocess to fail.  Got: %v, Want: exit status", err)
+	}
+	if want := "hook called: precondition failed: deliberate failure"; !strings.Contains(string(out),
+		want) {
+		t.Errorf("Expected the hook's output.  Got: %s, Want: %s", out, want)
+	}
+}
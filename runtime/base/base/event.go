@@ -15,10 +15,24 @@
 
 package base
 
+import "sync"
+
 // Event represents an auto-reset event used for multi-threaded signalling.
 type Event struct {
-	// signal is the channel used to indicate when the event is currently signalled.
-	signal chan bool
+	// lock protects set, signalled, queued and closed.
+	lock sync.Mutex
+
+	// set is the EventSet the event has been added to (if any).
+	set *EventSet
+
+	// signalled is true if the event has been signalled and not yet chosen.
+	signalled bool
+
+	// queued is true if the event is on its set's ready list.
+	queued bool
+
+	// closed is true if the event has been closed.
+	closed bool
 
 	// signal is an immutable context associated with the Event.  This value is provided at
 	// construction and is never mutated by the Event.  It is provided here for application use only.
@@ -27,21 +41,34 @@ type Event struct {
 
 // NewEvent creates an empty Event with no invalidation or value in the cell.
 func NewEvent(data interface{}) *Event {
-	// Make a single element channel so that an invalidation can be kept in it and a non-blocking
-	// send can used in Put.
 	return &Event{
-		signal: make(chan bool, 1),
-		data:   data,
+		data: data,
 	}
 }
 
 // Signal sends a pulse that will wake up exactly one waiter.
 // THREADING: This method is multi-thread safe.
 func (e *Event) Signal() {
-	select {
-	case e.signal <- false:
-	default:
+	e.lock.Lock()
+	if e.closed || e.signalled {
+		e.lock.Unlock()
+		return
 	}
+	e.signalled = true
+	set := e.enqueueLocked()
+	e.lock.Unlock()
+
+	if set != nil {
+		set.push(e)
+	}
+}
+
+// IsClosed returns true if the event has been closed.
+// THREADING: This method is multi-thread safe.
+func (e *Event) IsClosed() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.closed
 }
 
 // Data returns the data element associated with this event.
@@ -50,6 +77,41 @@ func (e *Event) Data() interface{} {
 }
 
 // Close destroys the event and removes it from any EventSet it is a part of.
+// THREADING: This method is multi-thread safe.
 func (e *Event) Close() {
-	close(e.signal)
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return
+	}
+	e.closed = true
+	set := e.enqueueLocked()
+	e.lock.Unlock()
+
+	if set != nil {
+		set.push(e)
+	}
+}
+
+// enqueueLocked marks the event as queued and returns the set whose ready list it must be pushed
+// on, or nil if it is already queued or not part of a set.
+// REQUIRES: e.lock is held.
+func (e *Event) enqueueLocked() *EventSet {
+	if e.queued || (e.set == nil) {
+		return nil
+	}
+	e.queued = true
+	return e.set
+}
+
+// consume is called when the event is removed from its set's ready list.  Returns whether the
+// event was signalled (resetting it) and whether it has been closed.  An event that was both
+// signalled and closed remains marked as queued and MUST be pushed again so that its closure is
+// seen after its final signal.
+func (e *Event) consume() (signalled bool, closed bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	signalled, e.signalled = e.signalled, false
+	e.queued = signalled && e.closed
+	return signalled, e.closed
 }
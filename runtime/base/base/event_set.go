@@ -17,9 +17,13 @@ package base
 
 // This file describes an EventSet abstraction.  An EventSet is not itself multithread safe but it
 // can be used from a single thread to monitor the activities of others through their events.
+//
+// Events put themselves on their set's ready list when they are signalled or closed so choosing an
+// event takes constant time regardless of how many events are registered.
 
 import (
-	"reflect"
+	"sync"
+	"time"
 
 	"github.com/prolang/drydock/runtime/base/assert"
 )
@@ -27,98 +31,162 @@ import (
 // EventSet is a set of events that should be monitored.  It allows zero or more Events to be
 // watched for asychronous signalling.
 type EventSet struct {
-	// The cases using which the EventSet gets its work items
-	cases []reflect.SelectCase
+	// lock protects ready and head.
+	lock sync.Mutex
+
+	// ready is the FIFO queue of events that have been signalled or closed since they were last
+	// chosen.  Events before head have already been removed.
+	ready []*Event
+
+	// head is the index of the oldest event in ready.
+	head int
 
-	// The events that are being tracked in the set.
-	events []*Event
+	// wake is pulsed whenever an event is added to ready.  It has a capacity of one so that a pulse
+	// sent while no one is waiting is not lost.
+	wake chan struct{}
+
+	// count is the number of events registered in the set.
+	count int
 }
 
 // NewEventSet creates a new empty event set.
 func NewEventSet() *EventSet {
-	// Fill the cases array with the Done context and the internal queueing channel.
-	cases := make([]reflect.SelectCase, 1)
-	cases[0] = reflect.SelectCase{
-		reflect.SelectDefault,
-		reflect.ValueOf(nil),
-		reflect.ValueOf(nil),
-	}
 	return &EventSet{
-		cases:  cases,
-		events: []*Event{nil},
+		wake: make(chan struct{}, 1),
 	}
 }
 
 // Add registers an event with the set.  Registered events will be returned by Select/Wait when
 // they become signaled.
 func (w *EventSet) Add(e *Event) {
-	// Add the signal channel in the cases array and the event in the events array.
-	w.cases = append(w.cases, reflect.SelectCase{
-		reflect.SelectRecv,
-		reflect.ValueOf(e.signal),
-		reflect.ValueOf(nil),
-	})
-	w.events = append(w.events, e)
+	e.lock.Lock()
+	assert.True(e.set == nil, "Events can only be added to one set.")
+	e.set = w
+	var set *EventSet
+	if e.signalled || e.closed {
+		set = e.enqueueLocked()
+	}
+	e.lock.Unlock()
+
+	w.count++
+	if set != nil {
+		set.push(e)
+	}
+}
+
+// Len returns the number of events registered in the set.  Closed events are counted until they
+// have been removed by Select or Wait.
+func (w *EventSet) Len() int {
+	return w.count
 }
 
 // Select returns a chosen event or nil if no event is signalled.  Select never blocks.  If no
 // event is signalled at the time of the call Select returns nil immediately.  If no events are
 // registered then Select return nil immediately.
 func (w *EventSet) Select() *Event {
-	return w.choose(false)
+	for {
+		e := w.pop()
+		if e == nil {
+			return nil
+		}
+		if e = w.check(e); e != nil {
+			return e
+		}
+	}
 }
 
 // Wait returns a chosen event or nil if no events are registered.  If no events are signalled at
 // the time of the call Wait blocks until an event becomes signalled or all registered events
 // become unregistered.
 func (w *EventSet) Wait() *Event {
-	return w.choose(true)
+	for w.count > 0 {
+		if e := w.Select(); e != nil {
+			return e
+		}
+		if w.count == 0 {
+			break
+		}
+		<-w.wake
+	}
+
+	// No events are registered so return immediately with nil.
+	return nil
 }
 
-// choose returns the chosen event or nil if no event is ready.
-// wait is true if the choose should block for an available event.
-// If there are no regiestered then choose always returns immediately with nil.
-func (w *EventSet) choose(wait bool) *Event {
-	// Configure the default case to block or not depending on wait.
-	if wait {
-		w.cases[0].Dir = reflect.SelectRecv
-	} else {
-		w.cases[0].Dir = reflect.SelectDefault
-	}
+// Wake returns a channel that receives a value whenever an event becomes signalled.  Wake allows
+// the set to be monitored from a select statement (e.g. in an event loop that cannot block in
+// Wait).  Values are coalesced and may be stale, so a receive only indicates that Select should be
+// called; it does not guarantee that an event is ready.
+func (w *EventSet) Wake() <-chan struct{} {
+	return w.wake
+}
 
-	// Loop around until either:
-	// 1.) An event is chosen,
-	// 2.) All events have been unregistered,
-	// 3.) The default case is selected (if !wait).
-	for len(w.cases) > 1 {
-		chosen, _, recvOK := reflect.Select(w.cases)
+// WaitTimeout is like Wait but returns nil if no event becomes signalled within d.
+func (w *EventSet) WaitTimeout(d time.Duration) *Event {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-		// If there are no signalled events and we aren't blocking, then return immediately.
-		if chosen == 0 {
-			return nil
+	for w.count > 0 {
+		if e := w.Select(); e != nil {
+			return e
 		}
-
-		if recvOK {
-			return w.events[chosen]
+		if w.count == 0 {
+			break
+		}
+		select {
+		case <-w.wake:
+		case <-timer.C:
+			return w.Select()
 		}
+	}
+	return nil
+}
+
+// push adds an event to the ready list and wakes any waiter.
+// THREADING: This method is multi-thread safe.
+func (w *EventSet) push(e *Event) {
+	w.lock.Lock()
+	w.ready = append(w.ready, e)
+	w.lock.Unlock()
 
-		// The chosen event needs to be removed.
-		w.remove(chosen)
+	select {
+	case w.wake <- struct{}{}:
+	default:
 	}
+}
 
-	// No events are registered so return immediately with nil.
-	return nil
+// pop removes the oldest event from the ready list.  Returns nil if the ready list is empty.
+func (w *EventSet) pop() *Event {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.head == len(w.ready) {
+		return nil
+	}
+	e := w.ready[w.head]
+	w.ready[w.head] = nil
+	w.head++
+
+	// Reuse the ready list's storage once it has been drained.
+	if w.head == len(w.ready) {
+		w.head = 0
+		w.ready = w.ready[:0]
+	}
+	return e
 }
 
-// remove unregisters a closed event from the set.
-func (w *EventSet) remove(chosen int) {
-	assert.True(chosen != 0, "Never remove the default case")
-
-	// The event has been closed.  Remove it from the set.
-	// Put the last element into the relevant index and then remove the last element.
-	lastIndex := len(w.events) - 1
-	w.events[chosen] = w.events[lastIndex]
-	w.events = w.events[:lastIndex]
-	w.cases[chosen] = w.cases[lastIndex]
-	w.cases = w.cases[:lastIndex]
+// check returns e if it was chosen, or nil if it was closed (in which case it is unregistered).
+// An event signalled before it was closed is chosen once more before it is unregistered.
+func (w *EventSet) check(e *Event) *Event {
+	signalled, closed := e.consume()
+	switch {
+	case signalled && closed:
+		w.push(e)
+		return e
+	case closed:
+		w.count--
+		return nil
+	case signalled:
+		return e
+	}
+	return nil
 }
@@ -211,3 +211,123 @@ func (t *EventSetSuite) Closed3() {
 		t.Errorf("Expected no event choosen.  Got: %v, Want: nil", chosen)
 	}
 }
+
+// SignalledThenClosed verifies that an event signalled before it is closed is still chosen once.
+func (t *EventSetSuite) SignalledThenClosed() {
+	e1 := NewEvent(1)
+	es := NewEventSet()
+
+	es.Add(e1)
+	e1.Signal()
+	e1.Close()
+	if chosen := es.Select(); chosen != e1 {
+		t.Errorf("Expected e1.  Got: %v, Want: %v", chosen, e1)
+	}
+	if chosen := es.Wait(); chosen != nil {
+		t.Errorf("Expected nil.  Got: %v, Want: %v", chosen, nil)
+	}
+	if n := es.Len(); n != 0 {
+		t.Errorf("Expected empty set.  Got: %v, Want: %v", n, 0)
+	}
+}
+
+// SignalledBeforeAdd verifies that an event signalled before it is added to a set is chosen.
+func (t *EventSetSuite) SignalledBeforeAdd() {
+	e1 := NewEvent(1)
+	es := NewEventSet()
+
+	e1.Signal()
+	es.Add(e1)
+	if chosen := es.Select(); chosen != e1 {
+		t.Errorf("Expected e1.  Got: %v, Want: %v", chosen, e1)
+	}
+}
+
+// ManySignallers verifies that every event signalled concurrently is eventually chosen.
+func (t *EventSetSuite) ManySignallers() {
+	const count = 100
+	es := NewEventSet()
+	events := make([]*Event, count)
+	for i := range events {
+		events[i] = NewEvent(i)
+		es.Add(events[i])
+	}
+	for _, e := range events {
+		go e.Signal()
+	}
+
+	seen := make(map[*Event]bool)
+	for len(seen) < count {
+		e := es.Wait()
+		if e == nil {
+			t.Fatalf("Expected an event.  Got: nil, Want: non-nil")
+		}
+		seen[e] = true
+	}
+	if chosen := es.Select(); chosen != nil {
+		t.Errorf("Expected no event choosen.  Got: %v, Want: nil", chosen)
+	}
+}
+
+// benchmarkSelect measures the cost of choosing the single signalled event from a set of size
+// events.
+func benchmarkSelect(b *testing.B, size int) {
+	es := NewEventSet()
+	events := make([]*Event, size)
+	for i := range events {
+		events[i] = NewEvent(i)
+		es.Add(events[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		events[i%size].Signal()
+		if es.Select() == nil {
+			b.Fatalf("Expected an event.  Got: nil, Want: non-nil")
+		}
+	}
+}
+
+// benchmarkIdleSelect measures the cost of checking a set of size events when none are signalled.
+func benchmarkIdleSelect(b *testing.B, size int) {
+	es := NewEventSet()
+	for i := 0; i < size; i++ {
+		es.Add(NewEvent(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if es.Select() != nil {
+			b.Fatalf("Expected no event.  Got: non-nil, Want: nil")
+		}
+	}
+}
+
+func BenchmarkSelect10(b *testing.B)         { benchmarkSelect(b, 10) }
+func BenchmarkSelect1000(b *testing.B)       { benchmarkSelect(b, 1000) }
+func BenchmarkSelect100000(b *testing.B)     { benchmarkSelect(b, 100000) }
+func BenchmarkIdleSelect10(b *testing.B)     { benchmarkIdleSelect(b, 10) }
+func BenchmarkIdleSelect1000(b *testing.B)   { benchmarkIdleSelect(b, 1000) }
+func BenchmarkIdleSelect100000(b *testing.B) { benchmarkIdleSelect(b, 100000) }
+
+// WaitTimeout verifies that WaitTimeout returns a signalled event or nil after the timeout.
+func (t *EventSetSuite) WaitTimeout() {
+	e1 := NewEvent(1)
+	es := NewEventSet()
+	es.Add(e1)
+
+	start := time.Now()
+	if chosen := es.WaitTimeout(10 * time.Millisecond); chosen != nil {
+		t.Errorf("Expected timeout.  Got: %v, Want: nil", chosen)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected WaitTimeout to block.  Got: %v, Want: 10ms", elapsed)
+	}
+
+	go e1.Signal()
+	if chosen := es.WaitTimeout(time.Minute); chosen != e1 {
+		t.Errorf("Expected e1.  Got: %v, Want: %v", chosen, e1)
+	}
+}
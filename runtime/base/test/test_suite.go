@@ -54,6 +54,11 @@ func (t *Suite) Errorf(format string, args ...interface{}) {
 	t.t.Fail()
 }
 
+// Failed returns true if the currently running test method has failed.
+func (t *Suite) Failed() bool {
+	return t.failed
+}
+
 // Fatalf implements log.Fatalf for a Suite and fails the current test and suite.
 func (t *Suite) Fatalf(format string, args ...interface{}) {
 	t.failed = true
@@ -26,16 +26,53 @@ import (
 // main is the initial turn to be executed and the manager continues execution until main's return
 // value is resolved.
 func RunActor(root async.Func) error {
-	done := make(chan error, 1)
+	return RunActorWith(nil, root)
+}
+
+// RunActorWith is like RunActor but calls configure (if not nil) with the actor's turn manager
+// before any turns are run.  This allows e.g. the manager's priority policy, loop budget or test
+// modes to be set.
+func RunActorWith(configure func(m *turns.Manager), root async.Func) error {
+	return start(configure, root, false).Wait()
+}
+
+// Start is like RunActor but returns immediately with a handle to the running actor.  Code running
+// on any goroutine can schedule work on the actor through the handle (see Post and Invoke).
+// Because work may be posted at any time, an actor started with Start never reports a
+// DeadlockError; it runs until root's result is resolved.
+func Start(root async.Func) *Handle {
+	return StartWith(nil, root)
+}
+
+// StartWith is like Start but calls configure (if not nil) with the actor's turn manager before
+// any turns are run.
+func StartWith(configure func(m *turns.Manager), root async.Func) *Handle {
+	return start(configure, root, true)
+}
+
+// start runs root in a new actor and returns its handle once the actor is ready to accept posted
+// work.  The handle only has an inbox if withInbox is true.
+func start(configure func(m *turns.Manager), root async.Func, withInbox bool) *Handle {
+	h := newHandle()
+	ready := make(chan struct{})
 
 	go func() {
 		manager := turns.NewManager(turns.NewUniqueIDGenerator())
+		if configure != nil {
+			configure(manager)
+		}
 		runner := turns.NewTurnRunner(manager)
 		tlsRelease := async.SetAmbientRunner(runner)
 		defer tlsRelease()
+		h.runner = runner
+
+		if withInbox {
+			h.inbox = turns.NewInbox("inbox")
+		}
+		close(ready)
 
 		// Allocate a resolver to track the completion of the "main" function.
-		r, s := async.NewR()
+		r, s := async.NewROn(runner)
 
 		// Queue to main routine for execution.
 		manager.NewTurn("Main", func() {
@@ -47,10 +84,16 @@ func RunActor(root async.Func) error {
 		})
 
 		err := manager.RunUntil(r)
-		done <- err
-		close(done)
+		if manager.IsTrackingResults() {
+			// Every result created by the actor should have been resolved by the time it exits.
+			for _, u := range manager.UnresolvedResults() {
+				log.Warningf("Actor exited with an orphaned %v", u)
+			}
+		}
+		manager.Close()
+		h.exit(err)
 	}()
 
-	err := <-done
-	return err
+	<-ready
+	return h
 }
@@ -0,0 +1,98 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor
+
+// This file contains the blocking bridge from asynchronous results to synchronous callers.  Code
+// that is not running on an actor (e.g. main, an HTTP handler or a library callback) can run an
+// asynchronous computation on an actor and block until its result is resolved.
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+)
+
+// reflectTypeAwaitableT is the type of async.AwaitableT.
+var reflectTypeAwaitableT = reflect.TypeOf((*async.AwaitableT)(nil)).Elem()
+
+// Await runs f in a new turn on the actor and blocks until the result returned by f is resolved,
+// the actor exits (ErrActorExited) or ctx is done (ctx.Err()).  f MUST be a function that takes no
+// arguments and returns a result (e.g. func() async.StringR).  The value of the result is returned.
+// If ctx is done first the computation is NOT cancelled; its outcome is discarded.
+//
+// Await MUST NOT be called from the actor's own thread: the actor cannot run f while it is blocked
+// waiting for f, so Await panics rather than deadlocking.
+func Await(ctx context.Context, h *Handle, f interface{}) (interface{}, error) {
+	fValue := reflect.ValueOf(f)
+	fType := fValue.Type()
+	if (fType.Kind() != reflect.Func) || (fType.NumIn() != 0) || (fType.NumOut() != 1) ||
+		!fType.Out(0).Implements(reflectTypeAwaitableT) {
+		panic(fmt.Sprintf("actor.Await: f MUST be a func() returning a result.  Got: %v", fType))
+	}
+	return await(ctx, h, func() async.AwaitableT {
+		return fValue.Call(nil)[0].Interface().(async.AwaitableT)
+	})
+}
+
+// AwaitR is like Await for a computation with no return value.
+func AwaitR(ctx context.Context, h *Handle, f async.Func) error {
+	_, err := await(ctx, h, func() async.AwaitableT {
+		return f()
+	})
+	return err
+}
+
+// AwaitString is like Await for a computation that returns a string.
+func AwaitString(ctx context.Context, h *Handle, f func() async.StringR) (string, error) {
+	value, err := await(ctx, h, func() async.AwaitableT {
+		return f()
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// outcome is the value and error of a resolved result.
+type outcome struct {
+	value interface{}
+	err   error
+}
+
+// await implements Await.
+func await(ctx context.Context, h *Handle, f func() async.AwaitableT) (interface{}, error) {
+	// The check is only meaningful while the actor runs: once it has exited its thread may be
+	// reused by other goroutines.
+	if async.IsCurrentRunner(h.runner) && !h.isExited() {
+		panic("actor.Await called on the actor's own thread would deadlock")
+	}
+
+	c := make(chan outcome, 1)
+	h.invoke(f, func(value interface{}, err error) {
+		c <- outcome{value, err}
+	})
+	select {
+	case o := <-c:
+		if o.err != nil {
+			return nil, o.err
+		}
+		return o.value, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// AwaitSuite is the test suite for Await.
+type AwaitSuite struct {
+	test.Suite
+}
+
+// TestAwaitSuite runs the test suite for Await.
+func TestAwaitSuite(t *testing.T) {
+	test.RunSuite(t, new(AwaitSuite))
+}
+
+// startServer starts an actor that runs until stopped.
+func startServer() (*actor.Handle, func()) {
+	var stop async.S
+	h := actor.Start(func() async.R {
+		r, s := async.NewR()
+		stop = s
+		return r
+	})
+	return h, func() {
+		actor.Post(h, func() { stop.Complete() })
+		h.Wait()
+	}
+}
+
+// Value verifies that the value of the result is returned to the caller.
+func (t *AwaitSuite) Value() {
+	h, stop := startServer()
+	defer stop()
+
+	got, err := actor.AwaitString(context.Background(), h, func() async.StringR {
+		r, s := async.NewStringR()
+		async.When(async.After(time.Millisecond), func() {
+			s.Complete("hello")
+		})
+		return r
+	})
+	if (err != nil) || (got != "hello") {
+		t.Errorf("Expected value.  Got: %q, %v, Want: hello", got, err)
+	}
+
+	value, err := actor.Await(context.Background(), h, func() async.StringR {
+		return async.NewStringErrorf("boom")
+	})
+	if (err == nil) || (err.Error() != "boom") || (value != nil) {
+		t.Errorf("Expected error.  Got: %v, %v, Want: boom", value, err)
+	}
+}
+
+// Deadline verifies that Await returns when its context is done.
+func (t *AwaitSuite) Deadline() {
+	h, stop := startServer()
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := actor.AwaitR(ctx, h, func() async.R {
+		r, _ := async.NewR()
+		return r
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline.  Got: %v, Want: %v", err, context.DeadlineExceeded)
+	}
+}
+
+// Exited verifies that Await fails once the actor has exited.
+func (t *AwaitSuite) Exited() {
+	h, stop := startServer()
+	stop()
+
+	err := actor.AwaitR(context.Background(), h, func() async.R {
+		return async.Done()
+	})
+	if err != actor.ErrActorExited {
+		t.Errorf("Expected exited.  Got: %v, Want: %v", err, actor.ErrActorExited)
+	}
+}
+
+// SelfDeadlock verifies that Await panics when called on the actor's own thread.
+func (t *AwaitSuite) SelfDeadlock() {
+	h, stop := startServer()
+	defer stop()
+
+	var recovered interface{}
+	<-actor.Invoke(h, func() (r async.R) {
+		defer func() {
+			recovered = recover()
+			r = async.Done()
+		}()
+		actor.AwaitR(context.Background(), h, func() async.R {
+			return async.Done()
+		})
+		return async.Done()
+	})
+	if recovered == nil {
+		t.Errorf("Expected a panic.  Got: nil, Want: panic")
+	}
+}
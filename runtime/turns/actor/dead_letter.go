@@ -0,0 +1,77 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor
+
+// This file contains the dead letter sink.  Messages that cannot be delivered (because they were
+// sent to an unknown or stopped actor, or were dropped by a full mailbox) are handed to the dead
+// letter sink for diagnostic purposes.
+
+import (
+	"sync"
+
+	log "github.com/golang/glog"
+)
+
+// DeadLetter is a message that could not be delivered.
+type DeadLetter struct {
+	// To is the address the message was sent to.
+	To Address
+
+	// Message is the undelivered message.
+	Message Message
+
+	// Reason describes why the message was not delivered.
+	Reason error
+}
+
+// DeadLetterSink receives undelivered messages.
+// THREADING: a sink may be called concurrently from any thread and MUST be multi-thread safe.  A
+// sink MUST NOT block.
+type DeadLetterSink func(d DeadLetter)
+
+// SetDeadLetterSink replaces the process-wide dead letter sink and returns the previous one.  If
+// sink is nil then dead letters are logged.
+func SetDeadLetterSink(sink DeadLetterSink) /*previous*/ DeadLetterSink {
+	deadLetterLock.Lock()
+	previous := deadLetterSink
+	deadLetterSink = sink
+	deadLetterLock.Unlock()
+	return previous
+}
+
+// deadLetterSink is the current process-wide sink.  If nil dead letters are logged.
+var deadLetterSink DeadLetterSink
+
+// deadLetterLock protects deadLetterSink.
+var deadLetterLock sync.RWMutex
+
+// deadLetter delivers an undeliverable message to the current dead letter sink.
+func deadLetter(to Address, msg Message, reason error) {
+	deadLetterLock.RLock()
+	sink := deadLetterSink
+	deadLetterLock.RUnlock()
+
+	d := DeadLetter{
+		To:      to,
+		Message: msg,
+		Reason:  reason,
+	}
+	if sink == nil {
+		log.Warningf("Dead letter to %v: %v (%v)", d.To, d.Message, d.Reason)
+		return
+	}
+	sink(d)
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor
+
+// This file contains the Handle to a running actor.  A handle allows code that is not running on
+// the actor (e.g. HTTP handlers or library callbacks on arbitrary goroutines) to schedule work on
+// the actor.  The work is marshalled onto the actor's thread through an inbox and runs in a turn
+// like any other, so it may freely use the actor's state and the async package.
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// ErrActorExited is returned when scheduling work on an actor that has exited, and by Invoke for
+// work that had not completed when the actor exited.
+var ErrActorExited = errors.New("actor exited")
+
+// Handle is a reference to a running actor.
+// THREADING: All methods of Handle are multi-thread safe.
+type Handle struct {
+	// inbox (if not nil) delivers posted work to the actor.
+	inbox *turns.Inbox
+
+	// done is closed when the actor exits.
+	done chan struct{}
+
+	// runner is the actor's ambient runner.
+	runner async.Runner
+
+	// lock protects err, exited and invocations.
+	lock sync.Mutex
+
+	// err is the outcome of the actor's root computation.
+	err error
+
+	// exited is true once the actor has exited.
+	exited bool
+
+	// invocations are the invocations that have not yet completed.
+	invocations map[*invocation]bool
+}
+
+// invocation is work scheduled on the actor whose outcome is returned to another goroutine.
+type invocation struct {
+	// complete is called exactly once with the outcome of the work.
+	complete func(value interface{}, err error)
+}
+
+// newHandle creates a handle for an actor that is starting.
+func newHandle() *Handle {
+	return &Handle{
+		done:        make(chan struct{}),
+		invocations: make(map[*invocation]bool),
+	}
+}
+
+// Done returns a channel that is closed when the actor exits.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the actor exits and returns the outcome of its root computation.
+func (h *Handle) Wait() error {
+	<-h.done
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.err
+}
+
+// isExited returns true if the actor has exited.
+func (h *Handle) isExited() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.exited
+}
+
+// exit records the actor's outcome and fails any invocations that have not completed.
+// THREADING: exit MUST be called on the actor's thread after its manager has stopped.
+func (h *Handle) exit(err error) {
+	if h.inbox != nil {
+		h.inbox.Close()
+	}
+
+	h.lock.Lock()
+	h.err, h.exited = err, true
+	for inv := range h.invocations {
+		inv.complete(nil, ErrActorExited)
+	}
+	h.invocations = nil
+	h.lock.Unlock()
+
+	close(h.done)
+}
+
+// Post schedules f to run in a new turn on the actor.  Returns ErrActorExited if the actor has
+// already exited.  Work posted concurrently with the actor's exit may not run.
+func Post(h *Handle, f func()) error {
+	if h.inbox == nil {
+		return ErrActorExited
+	}
+	if err := h.inbox.Post(f); err != nil {
+		return ErrActorExited
+	}
+	return nil
+}
+
+// Invoke schedules f to run in a new turn on the actor and returns a channel that receives the
+// outcome of the result returned by f.  If the actor exits before the result is resolved then the
+// channel receives ErrActorExited instead.  Exactly one value is sent on the channel.
+func Invoke(h *Handle, f async.Func) <-chan error {
+	c := make(chan error, 1)
+	h.invoke(func() async.AwaitableT {
+		return f()
+	}, func(_ interface{}, err error) {
+		c <- err
+	})
+	return c
+}
+
+// invoke schedules f to run in a new turn on the actor and calls complete exactly once with the
+// outcome of the result returned by f (or ErrActorExited if the actor exits first).  complete MUST
+// NOT block.
+func (h *Handle) invoke(f func() async.AwaitableT, complete func(value interface{}, err error)) {
+	inv := &invocation{complete: complete}
+
+	h.lock.Lock()
+	if h.exited || (h.inbox == nil) {
+		h.lock.Unlock()
+		complete(nil, ErrActorExited)
+		return
+	}
+	h.invocations[inv] = true
+	h.lock.Unlock()
+
+	err := h.inbox.Post(func() {
+		async.When(f(), func(value interface{}, err error) {
+			h.complete(inv, value, err)
+		})
+	})
+	if err != nil {
+		h.complete(inv, nil, ErrActorExited)
+	}
+}
+
+// complete reports the outcome of an invocation unless it has already been reported.
+func (h *Handle) complete(inv *invocation, value interface{}, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.invocations[inv] {
+		delete(h.invocations, inv)
+		inv.complete(value, err)
+	}
+}
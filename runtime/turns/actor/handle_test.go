@@ -0,0 +1,122 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// HandleSuite is the test suite for Handle.
+type HandleSuite struct {
+	test.Suite
+}
+
+// TestHandleSuite runs the test suite for Handle.
+func TestHandleSuite(t *testing.T) {
+	test.RunSuite(t, new(HandleSuite))
+}
+
+// counter is an actor that exits once it has been incremented count times.
+type counter struct {
+	value int
+	count int
+	exit  async.S
+}
+
+// start starts the counter actor.
+func (c *counter) start() *actor.Handle {
+	return actor.Start(func() async.R {
+		r, s := async.NewR()
+		c.exit = s
+		return r
+	})
+}
+
+// increment increments the counter.
+// THREADING: MUST be called on the counter's actor.
+func (c *counter) increment() {
+	c.value++
+	if c.value == c.count {
+		c.exit.Complete()
+	}
+}
+
+// Post verifies that work posted from many goroutines runs on the actor.
+func (t *HandleSuite) Post() {
+	c := &counter{count: 100}
+	h := c.start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := actor.Post(h, c.increment); err != nil {
+				t.Errorf("Expected post to succeed.  Got: %v, Want: nil", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := h.Wait(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if c.value != c.count {
+		t.Errorf("Expected every post to run.  Got: %v, Want: %v", c.value, c.count)
+	}
+	if err := actor.Post(h, c.increment); err != actor.ErrActorExited {
+		t.Errorf("Expected exited.  Got: %v, Want: %v", err, actor.ErrActorExited)
+	}
+}
+
+// Invoke verifies that the outcome of invoked work is returned to the caller.
+func (t *HandleSuite) Invoke() {
+	c := &counter{count: 2}
+	h := c.start()
+
+	if err := <-actor.Invoke(h, func() async.R {
+		c.increment()
+		return async.Done()
+	}); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+
+	boom := errors.New("boom")
+	if err := <-actor.Invoke(h, func() async.R {
+		return async.NewError(boom)
+	}); err != boom {
+		t.Errorf("Expected failure.  Got: %v, Want: %v", err, boom)
+	}
+
+	// Work whose result is never resolved fails when the actor exits.
+	pending := actor.Invoke(h, func() async.R {
+		r, _ := async.NewR()
+		c.increment()
+		return r
+	})
+	if err := <-pending; err != actor.ErrActorExited {
+		t.Errorf("Expected exited.  Got: %v, Want: %v", err, actor.ErrActorExited)
+	}
+	if err := <-actor.Invoke(h, func() async.R { return async.Done() }); err != actor.ErrActorExited {
+		t.Errorf("Expected exited.  Got: %v, Want: %v", err, actor.ErrActorExited)
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor
+
+// This file contains the definition of a Mailbox which is the destination of messages sent between
+// actors.  A Mailbox is owned by exactly one actor (the actor that created it) and only that actor
+// may receive from it.  Any actor may send to a Mailbox through its Address.
+//
+// Mailboxes may be bounded.  When a message is sent to a full mailbox the mailbox's OverflowPolicy
+// determines the outcome:
+//
+//   OverflowBlock        the sender's result is resolved once the message has been accepted.
+//   OverflowDropNewest   the message being sent is discarded to the dead letter sink.
+//   OverflowDropOldest   the oldest queued message is discarded to the dead letter sink.
+//   OverflowFail         the sender's result fails with ErrMailboxFull.
+//
+// Messages sent by a single sender to a single mailbox are always delivered in FIFO order.
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// Errors returned by message passing operations.
+var (
+	// ErrMailboxFull is returned when sending to a full mailbox whose policy is OverflowFail.
+	ErrMailboxFull = errors.New("mailbox full")
+
+	// ErrMailboxClosed is returned when sending to or receiving from a mailbox that has been closed.
+	ErrMailboxClosed = errors.New("mailbox closed")
+
+	// ErrUnknownActor is returned when sending to an address for which no mailbox exists.
+	ErrUnknownActor = errors.New("unknown actor")
+
+	// ErrDuplicateName is returned when creating a mailbox with a name that is already in use.
+	ErrDuplicateName = errors.New("mailbox name already in use")
+)
+
+// Message is the payload of a message sent between actors.  Messages are transferred by ownership:
+// once sent, the sender MUST NOT access the message again.
+type Message interface{}
+
+// MessageR tracks the completion progress of a Receive.
+type MessageR struct {
+	async.ResultT
+}
+
+// Type implements AwaitableT.Type().
+func (MessageR) Type() reflect.Type {
+	return reflect.TypeOf((*Message)(nil)).Elem()
+}
+
+// Address identifies a Mailbox.  Addresses are values and may be freely copied and sent to other
+// actors in messages.  The zero Address identifies no mailbox.
+type Address struct {
+	// Node is the name of the process containing the mailbox.  An empty Node refers to the local
+	// process.
+	Node string
+
+	// Name is the process-unique name of the mailbox.
+	Name string
+}
+
+// IsLocal returns true if the address refers to a mailbox in this process.
+func (a Address) IsLocal() bool {
+	return a.Node == "" || a.Node == LocalNode()
+}
+
+// String implements fmt.Stringer
+func (a Address) String() string {
+	if a.Node == "" {
+		return a.Name
+	}
+	return a.Node + "/" + a.Name
+}
+
+// OverflowPolicy determines the behavior of a bounded Mailbox when a message is sent to it while
+// it is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock resolves the sender's result only once there is room for the message.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the message being sent.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest message in the mailbox to make room.
+	OverflowDropOldest
+
+	// OverflowFail fails the sender's result with ErrMailboxFull.
+	OverflowFail
+)
+
+// String implements fmt.Stringer
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowFail:
+		return "Fail"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// MailboxConfig configures a new Mailbox.
+type MailboxConfig struct {
+	// Capacity is the maximum number of undelivered messages the mailbox holds.  Zero means the
+	// mailbox is unbounded.
+	Capacity int
+
+	// Policy determines the behavior when a message is sent to a full mailbox.
+	Policy OverflowPolicy
+}
+
+// Mailbox is a FIFO queue of messages sent to an actor.
+type Mailbox struct {
+	// address is the address at which this mailbox receives messages.
+	address Address
+
+	// config is the immutable configuration of this mailbox.
+	config MailboxConfig
+
+	// source is the I/O source on which receives are completed.
+	source async.Source
+
+	// runner is the runner of the actor that created the mailbox.
+	runner async.Runner
+
+	// receiving is true while a Receive is outstanding.
+	// THREADING: only accessed by the owning actor.
+	receiving bool
+
+	// closing is true once Close has been called.
+	// THREADING: only accessed by the owning actor.
+	closing bool
+
+	// lock protects the fields below.
+	lock sync.Mutex
+
+	// ready is signalled when a message arrives or the mailbox is closed.
+	ready *sync.Cond
+
+	// closed is true once the mailbox has been closed.
+	closed bool
+
+	// messages is the queue of undelivered messages in FIFO order.
+	messages []Message
+
+	// senders is the queue of senders blocked waiting for room in FIFO order.
+	senders []*blockedSender
+}
+
+// blockedSender is a message waiting for room in a full mailbox.
+type blockedSender struct {
+	// msg is the message to be delivered.
+	msg Message
+
+	// done receives the outcome once the message is accepted or rejected.
+	done chan error
+}
+
+// directory maps mailbox names to the mailboxes in this process.
+var directory = make(map[string]*Mailbox)
+
+// directoryLock protects directory.
+var directoryLock sync.RWMutex
+
+// mailboxNames generates names for mailboxes created without one.
+var mailboxNames = turns.NewUniqueIDGenerator()
+
+// mailboxNamesLock protects mailboxNames.
+var mailboxNamesLock sync.Mutex
+
+// NewMailbox creates a new mailbox owned by the current actor.  If name is empty a unique name is
+// generated.  Returns ErrDuplicateName if a mailbox with the same name already exists.
+// REQUIRES: the caller must call Close when the mailbox is no longer needed.
+func NewMailbox(name string, config MailboxConfig) (*Mailbox, error) {
+	assert.True(config.Capacity >= 0, "Mailbox capacity cannot be negative: %d", config.Capacity)
+
+	if name == "" {
+		mailboxNamesLock.Lock()
+		name = "mailbox" + mailboxNames.NewID().String()
+		mailboxNamesLock.Unlock()
+	}
+
+	mb := &Mailbox{
+		address: Address{Name: name},
+		config:  config,
+	}
+	mb.ready = sync.NewCond(&mb.lock)
+
+	directoryLock.Lock()
+	if _, exists := directory[name]; exists {
+		directoryLock.Unlock()
+		return nil, ErrDuplicateName
+	}
+	directory[name] = mb
+	directoryLock.Unlock()
+
+	mb.source, mb.runner = turns.NewTurnSource(), async.GetCurrentRunner()
+	return mb, nil
+}
+
+// Address returns the address at which this mailbox receives messages.
+func (mb *Mailbox) Address() Address {
+	return Address{
+		Node: LocalNode(),
+		Name: mb.address.Name,
+	}
+}
+
+// Len returns the number of messages waiting to be received.
+func (mb *Mailbox) Len() int {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	return len(mb.messages)
+}
+
+// Receive returns the next message in the mailbox.  If the mailbox is empty the result is resolved
+// when a message arrives.  If the mailbox is closed the result fails with ErrMailboxClosed.
+// REQUIRES: at most one Receive may be outstanding at a time.
+func (mb *Mailbox) Receive() MessageR {
+	assert.True(!mb.receiving, "Only one Receive may be outstanding on mailbox %v.", mb.address)
+
+	r, s := mb.runner.NewResultT()
+	if msg, ok, err := mb.tryTake(); ok || err != nil {
+		s.Resolve(msg, err)
+		return MessageR{r}
+	}
+
+	// Wait for a message on an I/O thread.
+	var msg Message
+	mb.receiving = true
+	io := mb.source.New(func() error {
+		var err error
+		msg, err = mb.take()
+		return err
+	})
+	async.When(io, func(err error) {
+		mb.receiving = false
+		if mb.closing {
+			mb.source.Close()
+		}
+		s.Resolve(msg, err)
+	})
+	return MessageR{r}
+}
+
+// Close unregisters the mailbox.  Messages still in the mailbox, and messages from senders blocked
+// on the mailbox, are delivered to the dead letter sink.  Any outstanding Receive fails with
+// ErrMailboxClosed.
+func (mb *Mailbox) Close() {
+	assert.True(!mb.closing, "Mailbox %v already closed.", mb.address)
+	mb.closing = true
+
+	directoryLock.Lock()
+	delete(directory, mb.address.Name)
+	directoryLock.Unlock()
+
+	mb.lock.Lock()
+	mb.closed = true
+	messages, senders := mb.messages, mb.senders
+	mb.messages, mb.senders = nil, nil
+	mb.ready.Broadcast()
+	mb.lock.Unlock()
+
+	for _, msg := range messages {
+		deadLetter(mb.address, msg, ErrMailboxClosed)
+	}
+	for _, b := range senders {
+		deadLetter(mb.address, b.msg, ErrMailboxClosed)
+		b.done <- ErrMailboxClosed
+	}
+
+	// If a Receive is outstanding then the source is closed once it completes.
+	if !mb.receiving {
+		mb.source.Close()
+	}
+}
+
+// tryTake removes the message at the head of the mailbox if there is one.  Returns ok if a message
+// was removed.
+func (mb *Mailbox) tryTake() (msg Message, ok bool, err error) {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	if len(mb.messages) > 0 {
+		return mb.popLocked(), true, nil
+	}
+	if mb.closed {
+		return nil, false, ErrMailboxClosed
+	}
+	return nil, false, nil
+}
+
+// take removes the message at the head of the mailbox, blocking until one arrives.
+// THREADING: this method blocks and MUST only be called from an I/O thread.
+func (mb *Mailbox) take() (Message, error) {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	for len(mb.messages) == 0 {
+		if mb.closed {
+			return nil, ErrMailboxClosed
+		}
+		mb.ready.Wait()
+	}
+	return mb.popLocked(), nil
+}
+
+// popLocked removes the message at the head of the mailbox and admits the first blocked sender,
+// if any, into the room made available.
+// REQUIRES: lock is held and the mailbox is non-empty.
+func (mb *Mailbox) popLocked() Message {
+	msg := mb.messages[0]
+	mb.messages[0] = nil
+	mb.messages = mb.messages[1:]
+
+	if len(mb.senders) > 0 {
+		b := mb.senders[0]
+		mb.senders[0] = nil
+		mb.senders = mb.senders[1:]
+		mb.messages = append(mb.messages, b.msg)
+		b.done <- nil
+	}
+	return msg
+}
+
+// put adds a message to the mailbox according to the mailbox's overflow policy.  If the sender
+// must block then put returns a non-nil blockedSender on which the outcome will be delivered.  Any
+// message discarded to make room (or instead of making room) is returned in dropped.
+// THREADING: this method is multi-thread safe.
+func (mb *Mailbox) put(msg Message) (b *blockedSender, dropped []Message, err error) {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+
+	if mb.closed {
+		return nil, nil, ErrMailboxClosed
+	}
+
+	// Messages always queue behind blocked senders to preserve FIFO order.
+	if mb.config.Capacity == 0 || (len(mb.messages) < mb.config.Capacity && len(mb.senders) == 0) {
+		mb.messages = append(mb.messages, msg)
+		mb.ready.Signal()
+		return nil, nil, nil
+	}
+
+	switch mb.config.Policy {
+	case OverflowBlock:
+		b = &blockedSender{
+			msg:  msg,
+			done: make(chan error, 1),
+		}
+		mb.senders = append(mb.senders, b)
+		return b, nil, nil
+	case OverflowDropNewest:
+		return nil, []Message{msg}, nil
+	case OverflowDropOldest:
+		oldest := mb.messages[0]
+		mb.messages[0] = nil
+		mb.messages = append(mb.messages[1:], msg)
+		return nil, []Message{oldest}, nil
+	case OverflowFail:
+		return nil, nil, ErrMailboxFull
+	}
+	assert.True(false, "Unknown overflow policy: %v", mb.config.Policy)
+	return nil, nil, nil
+}
+
+// lookup returns the mailbox at address or nil if there is no such mailbox.
+func lookup(to Address) *Mailbox {
+	directoryLock.RLock()
+	mb := directory[to.Name]
+	directoryLock.RUnlock()
+	return mb
+}
+
+// deliver adds a message to the mailbox in this process at address to.  If the sender must wait
+// for room then deliver returns a channel on which the outcome will be delivered.
+// THREADING: this method is multi-thread safe.
+func deliver(to Address, msg Message) (<-chan error, error) {
+	mb := lookup(to)
+	if mb == nil {
+		deadLetter(to, msg, ErrUnknownActor)
+		return nil, ErrUnknownActor
+	}
+
+	b, dropped, err := mb.put(msg)
+	for _, d := range dropped {
+		deadLetter(to, d, ErrMailboxFull)
+	}
+	if err != nil {
+		if err == ErrMailboxClosed {
+			deadLetter(to, msg, err)
+		}
+		return nil, err
+	}
+	if b == nil {
+		return nil, nil
+	}
+	return b.done, nil
+}
+
+// Send delivers a message to the mailbox at address to.  The returned result is resolved once the
+// message has been accepted by the mailbox (not when it has been received).  If there is no
+// mailbox at address to, or the mailbox is closed, then the message is delivered to the dead letter
+// sink and the result fails with ErrUnknownActor or ErrMailboxClosed respectively.
+//
+// If address to is in another process then the message is handed to the Router registered for
+// that node.  See Router for the delivery guarantees of remote messages.
+func Send(to Address, msg Message) async.R {
+	var done <-chan error
+	var err error
+	if to.IsLocal() {
+		done, err = deliver(to, msg)
+	} else {
+		done, err = route(to, msg)
+	}
+	if err != nil {
+		return async.NewError(err)
+	}
+	if done == nil {
+		return async.Done()
+	}
+
+	// Wait on an I/O thread for the message to be accepted.
+	src := turns.NewTurnSource()
+	r := src.New(func() error {
+		return <-done
+	})
+	return async.Finally(r, src.Close)
+}
+
+// Deliver delivers a message to the mailbox in this process at address to, ignoring the address's
+// node.  If the mailbox is full and its policy is OverflowBlock then Deliver blocks until there is
+// room.  Deliver is intended for transports that receive messages from other processes.
+// THREADING: this method is multi-thread safe but blocks and so MUST NOT be called by an actor.
+func Deliver(to Address, msg Message) error {
+	done, err := deliver(to, msg)
+	if err != nil || done == nil {
+		return err
+	}
+	return <-done
+}
@@ -0,0 +1,331 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// MailboxSuite is the test suite for Mailbox.
+type MailboxSuite struct {
+	test.Suite
+}
+
+// TestMailboxSuite runs the test suite for Mailbox.
+func TestMailboxSuite(t *testing.T) {
+	test.RunSuite(t, new(MailboxSuite))
+}
+
+// deadLetters records the dead letters delivered while it is installed.
+type deadLetters struct {
+	lock     sync.Mutex
+	letters  []actor.DeadLetter
+	previous actor.DeadLetterSink
+}
+
+// newDeadLetters installs a new recording dead letter sink.
+func newDeadLetters() *deadLetters {
+	d := &deadLetters{}
+	d.previous = actor.SetDeadLetterSink(func(l actor.DeadLetter) {
+		d.lock.Lock()
+		d.letters = append(d.letters, l)
+		d.lock.Unlock()
+	})
+	return d
+}
+
+// get returns the dead letters recorded so far.
+func (d *deadLetters) get() []actor.DeadLetter {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]actor.DeadLetter(nil), d.letters...)
+}
+
+// release restores the previous dead letter sink.
+func (d *deadLetters) release() {
+	actor.SetDeadLetterSink(d.previous)
+}
+
+// expectReceive returns a result that fails unless the next message received is expected.
+func expectReceive(mb *actor.Mailbox, expected actor.Message) async.R {
+	return async.When(mb.Receive(), func(msg actor.Message, err error) error {
+		if err != nil {
+			return fmt.Errorf("Expected receive to succeed.  Got: %v, Want: nil", err)
+		}
+		if msg != expected {
+			return fmt.Errorf("Expected message.  Got: %v, Want: %v", msg, expected)
+		}
+		return nil
+	})
+}
+
+// SendReceive verifies that messages are received in the order they were sent.
+func (t *MailboxSuite) SendReceive() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "one")
+	actor.Send(mb.Address(), "two")
+	if n := mb.Len(); n != 2 {
+		t.Errorf("Expected queued messages.  Got: %v, Want: 2", n)
+	}
+
+	r := async.When(expectReceive(mb, "one"), func() async.R {
+		return expectReceive(mb, "two")
+	})
+	return async.Finally(r, mb.Close)
+}
+
+// ReceiveWaits verifies that a Receive on an empty mailbox completes when a message arrives.
+func (t *MailboxSuite) ReceiveWaits() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	r := expectReceive(mb, "late")
+	actor.Send(mb.Address(), "late")
+	return async.Finally(r, mb.Close)
+}
+
+// DuplicateName verifies that mailbox names are unique.
+func (t *MailboxSuite) DuplicateName() async.R {
+	mb, err := actor.NewMailbox("duplicate", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	defer mb.Close()
+
+	if _, err := actor.NewMailbox("duplicate", actor.MailboxConfig{}); err != actor.ErrDuplicateName {
+		return async.NewErrorf("Expected duplicate.  Got: %v, Want: %v", err, actor.ErrDuplicateName)
+	}
+	return async.Done()
+}
+
+// DropNewest verifies that a full DropNewest mailbox discards the message being sent.
+func (t *MailboxSuite) DropNewest() async.R {
+	d := newDeadLetters()
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{
+		Capacity: 1,
+		Policy:   actor.OverflowDropNewest,
+	})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "kept")
+	s := actor.Send(mb.Address(), "dropped")
+
+	r := async.When(s, func(err error) async.R {
+		if err != nil {
+			return async.NewErrorf("Expected drop to succeed.  Got: %v, Want: nil", err)
+		}
+		if letters := d.get(); len(letters) != 1 || letters[0].Message != "dropped" {
+			return async.NewErrorf("Expected dead letter.  Got: %v, Want: dropped", letters)
+		}
+		return expectReceive(mb, "kept")
+	})
+	return async.Finally(r, func() {
+		mb.Close()
+		d.release()
+	})
+}
+
+// DropOldest verifies that a full DropOldest mailbox discards the oldest message.
+func (t *MailboxSuite) DropOldest() async.R {
+	d := newDeadLetters()
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{
+		Capacity: 1,
+		Policy:   actor.OverflowDropOldest,
+	})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "dropped")
+	actor.Send(mb.Address(), "kept")
+
+	if letters := d.get(); len(letters) != 1 || letters[0].Message != "dropped" {
+		t.Errorf("Expected dead letter.  Got: %v, Want: dropped", letters)
+	}
+	r := expectReceive(mb, "kept")
+	return async.Finally(r, func() {
+		mb.Close()
+		d.release()
+	})
+}
+
+// Fail verifies that sending to a full Fail mailbox fails.
+func (t *MailboxSuite) Fail() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{
+		Capacity: 1,
+		Policy:   actor.OverflowFail,
+	})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "kept")
+	s := actor.Send(mb.Address(), "rejected")
+
+	r := async.When(s, func(err error) async.R {
+		if err != actor.ErrMailboxFull {
+			return async.NewErrorf("Expected full.  Got: %v, Want: %v", err, actor.ErrMailboxFull)
+		}
+		return expectReceive(mb, "kept")
+	})
+	return async.Finally(r, mb.Close)
+}
+
+// Block verifies that sending to a full Block mailbox completes only once there is room, and that
+// blocked messages are delivered in FIFO order.
+func (t *MailboxSuite) Block() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{
+		Capacity: 1,
+		Policy:   actor.OverflowBlock,
+	})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "one")
+	s2 := actor.Send(mb.Address(), "two")
+	s3 := actor.Send(mb.Address(), "three")
+
+	accepted := false
+	async.When(s2, func() {
+		accepted = true
+	})
+
+	r := async.When(expectReceive(mb, "one"), func() async.R {
+		return async.When(s2, func() async.R {
+			if !accepted {
+				return async.NewErrorf("Expected blocked send accepted.  Got: false, Want: true")
+			}
+			return expectReceive(mb, "two")
+		})
+	})
+	r = async.When(r, func() async.R {
+		return async.When(s3, func() async.R {
+			return expectReceive(mb, "three")
+		})
+	})
+	return async.Finally(r, mb.Close)
+}
+
+// UnknownActor verifies that messages sent to unknown actors become dead letters.
+func (t *MailboxSuite) UnknownActor() async.R {
+	d := newDeadLetters()
+	to := actor.Address{Name: "nobody"}
+	s := actor.Send(to, "lost")
+
+	return async.When(s, func(err error) error {
+		defer d.release()
+		if err != actor.ErrUnknownActor {
+			return fmt.Errorf("Expected unknown.  Got: %v, Want: %v", err, actor.ErrUnknownActor)
+		}
+		letters := d.get()
+		if len(letters) != 1 || letters[0].To != to || letters[0].Reason != actor.ErrUnknownActor {
+			return fmt.Errorf("Expected dead letter.  Got: %v, Want: lost", letters)
+		}
+		return nil
+	})
+}
+
+// Close verifies that closing a mailbox turns undelivered messages into dead letters and fails any
+// outstanding or subsequent Receive.
+func (t *MailboxSuite) Close() async.R {
+	d := newDeadLetters()
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	actor.Send(mb.Address(), "undelivered")
+	mb.Close()
+
+	if letters := d.get(); len(letters) != 1 || letters[0].Reason != actor.ErrMailboxClosed {
+		t.Errorf("Expected dead letter.  Got: %v, Want: undelivered", letters)
+	}
+
+	r := async.When(mb.Receive(), func(err error) error {
+		if err != actor.ErrMailboxClosed {
+			return fmt.Errorf("Expected closed.  Got: %v, Want: %v", err, actor.ErrMailboxClosed)
+		}
+		return nil
+	})
+	r = async.When(r, func() async.R {
+		return async.When(actor.Send(mb.Address(), "late"), func(err error) error {
+			if err != actor.ErrUnknownActor {
+				return fmt.Errorf("Expected unknown.  Got: %v, Want: %v", err, actor.ErrUnknownActor)
+			}
+			return nil
+		})
+	})
+	return async.Finally(r, d.release)
+}
+
+// CloseWhileReceiving verifies that closing a mailbox fails an outstanding Receive.
+func (t *MailboxSuite) CloseWhileReceiving() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	r := async.When(mb.Receive(), func(err error) error {
+		if err != actor.ErrMailboxClosed {
+			return fmt.Errorf("Expected closed.  Got: %v, Want: %v", err, actor.ErrMailboxClosed)
+		}
+		return nil
+	})
+	mb.Close()
+	return r
+}
+
+// BetweenActors verifies that messages can be exchanged between two actors.
+func (t *MailboxSuite) BetweenActors() async.R {
+	mb, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+
+	// Start a second actor that replies to every ping with a pong.
+	pinger := make(chan actor.Address, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- actor.RunActor(func() async.R {
+			peer, err := actor.NewMailbox("", actor.MailboxConfig{})
+			if err != nil {
+				return async.NewError(err)
+			}
+			pinger <- peer.Address()
+			r := async.When(peer.Receive(), func(msg actor.Message) async.R {
+				return actor.Send(msg.(actor.Address), "pong")
+			})
+			return async.Finally(r, peer.Close)
+		})
+	}()
+
+	r := async.When(actor.Send(<-pinger, mb.Address()), func() async.R {
+		return expectReceive(mb, "pong")
+	})
+	return async.Finally(r, func() {
+		mb.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Expected peer to succeed.  Got: %v, Want: nil", err)
+		}
+	})
+}
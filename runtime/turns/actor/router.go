@@ -0,0 +1,90 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package actor
+
+// This file contains the routing table used to send messages to actors in other processes.  Each
+// process (node) has a name.  Addresses whose Node is neither empty nor the local node's name are
+// handed to the Router registered for that node.
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUnknownNode is returned when sending to an address in a node for which no Router exists.
+var ErrUnknownNode = errors.New("unknown node")
+
+// Router delivers messages to mailboxes in another process.
+type Router interface {
+	// Route queues a message for delivery to the remote mailbox at address to and returns a channel
+	// on which the outcome of the delivery is sent.  Messages routed in a given order are
+	// transmitted in that order.
+	// THREADING: Route MUST be multi-thread safe and MUST NOT block.
+	Route(to Address, msg Message) <-chan error
+}
+
+// routes maps node names to the Router for that node.
+var routes = make(map[string]Router)
+
+// localNode is the name of this process.
+var localNode string
+
+// routesLock protects routes and localNode.
+var routesLock sync.RWMutex
+
+// SetLocalNode sets the name of this process.  Addresses with this Node are local.
+func SetLocalNode(node string) {
+	routesLock.Lock()
+	localNode = node
+	routesLock.Unlock()
+}
+
+// LocalNode returns the name of this process.
+func LocalNode() string {
+	routesLock.RLock()
+	defer routesLock.RUnlock()
+	return localNode
+}
+
+// RegisterRouter registers r as the Router for messages sent to node, replacing any existing
+// Router for node.
+func RegisterRouter(node string, r Router) {
+	routesLock.Lock()
+	routes[node] = r
+	routesLock.Unlock()
+}
+
+// UnregisterRouter removes the Router for node if it is r.
+func UnregisterRouter(node string, r Router) {
+	routesLock.Lock()
+	if routes[node] == r {
+		delete(routes, node)
+	}
+	routesLock.Unlock()
+}
+
+// route hands a message to the Router for the node of address to.
+func route(to Address, msg Message) (<-chan error, error) {
+	routesLock.RLock()
+	r := routes[to.Node]
+	routesLock.RUnlock()
+
+	if r == nil {
+		deadLetter(to, msg, ErrUnknownNode)
+		return nil, ErrUnknownNode
+	}
+	return r.Route(to, msg), nil
+}
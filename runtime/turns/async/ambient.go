@@ -24,43 +24,101 @@ import (
 
 // This file contains functions for setting and retrieving the ambient (default) runner.  Each actor
 // has exactly one ambient runner and in general only the actor framework should ever set one.
+//
+// The ambient runner is associated with the OS thread on which the actor runs (which the actor
+// locks to its goroutine for its lifetime).  Ambient runners nest: setting a runner on a thread
+// that already has one (e.g. running an actor from within another, or a simulation switching between
+// nodes) hides the previous runner until the new one is released.  Releasing the last runner on a
+// thread removes the thread's entry entirely so that nothing leaks and a later goroutine on the same
+// thread has no runner.
+//
+// Looking up the ambient runner takes no lock but costs a system call to identify the thread, so
+// the package-level conveniences that use it (New, NewR, Done, After, etc.) are best kept off hot
+// paths.  Code that already has a runner never needs the lookup: a result carries the runner on
+// which it was created (see RunnerOf and S.Runner), a When callback can take the runner as its
+// first argument (see AwaitableT.WhenT), and the runner itself creates results (see Runner.New,
+// Runner.Done and NewROn).
 
 type ReleaseFunc func()
 
-// SetAmbientRunner initializes the per-thread storage for an actor context.
-// REQUIRES: the caller must call the returned ReleaseFunc to destroy the per-thread context.
+// SetAmbientRunner initializes the per-thread storage for an actor context.  runner becomes the
+// ambient runner of the calling goroutine (whose OS thread is locked) until the returned ReleaseFunc
+// is called, at which point the thread's previous ambient runner (if any) is restored.
+// REQUIRES: the caller must call the returned ReleaseFunc (exactly once, on the same goroutine) to
+// destroy the per-thread context.  Nested runners MUST be released in reverse order.
 func SetAmbientRunner(runner Runner) ReleaseFunc {
 	runtime.LockOSThread()
 	tid := gettid()
-	threadContextLock.Lock()
-	threadContexts[tid] = threadContext{
-		runner: runner,
+	ctx := getThreadContext(tid)
+	if ctx == nil {
+		ctx = &threadContext{}
+		threadContexts.Store(tid, ctx)
 	}
-	threadContextLock.Unlock()
+	ctx.runners = append(ctx.runners, runner)
+	depth := len(ctx.runners)
+
+	released := false
 	return func() {
+		assert.True(!released, "ReleaseFunc MUST only be called once.")
+		assert.True(gettid() == tid, "ReleaseFunc MUST be called on the goroutine that set the runner.")
+		assert.True(len(ctx.runners) == depth, "Nested runners MUST be released in reverse order.")
+		released = true
+
+		ctx.runners[depth-1] = nil
+		ctx.runners = ctx.runners[:depth-1]
+		if len(ctx.runners) == 0 {
+			threadContexts.Delete(tid)
+		}
 		runtime.UnlockOSThread()
 	}
 }
 
 // GetCurrentRunner returns the current ambient (default) runner.
 func GetCurrentRunner() Runner {
-	tid := gettid()
-	threadContextLock.RLock()
-	runner := threadContexts[tid].runner
-	threadContextLock.RUnlock()
+	runner := currentRunner()
 	assert.True(runner != nil, "GetCurrentRunner can only be called by an actor.")
 	return runner
 }
 
+// IsCurrentRunner returns true if runner is the ambient runner of the calling thread (i.e. the
+// caller is running on runner's actor).
+func IsCurrentRunner(runner Runner) bool {
+	current := currentRunner()
+	return (current != nil) && (current == runner)
+}
+
+// RunnerOf returns the runner on which r was created.  Unlike GetCurrentRunner, RunnerOf does not
+// need to look up the calling thread.
+func RunnerOf(r AwaitableT) Runner {
+	return r.Base().s.Runner()
+}
+
+// currentRunner returns the ambient runner of the calling thread, or nil if there is none.
+func currentRunner() Runner {
+	ctx := getThreadContext(gettid())
+	if ctx == nil {
+		return nil
+	}
+	return ctx.runners[len(ctx.runners)-1]
+}
+
+// getThreadContext returns the context of thread tid, or nil if it has no ambient runner.
+func getThreadContext(tid int) *threadContext {
+	v, ok := threadContexts.Load(tid)
+	if !ok {
+		return nil
+	}
+	return v.(*threadContext)
+}
+
 // threadContexts contains thread-local storage for use by actors keyed by the OS thread-id of the
 // OS thread the actor is running on.  SetAmbientRunner must be called by an actor before any thread
-// local storage may be used.
-var threadContexts = make(map[int]threadContext)
-
-// threadContextLock protects threadContexts.
-var threadContextLock sync.RWMutex
+// local storage may be used.  Lookups take no lock.  Each threadContext is only ever read or written
+// by its own thread.
+var threadContexts sync.Map // map[int]*threadContext
 
 // threadContext contains thread-local storage for use by actors.
 type threadContext struct {
-	runner Runner
+	// runners is the stack of ambient runners set on the thread.  The last is current.
+	runners []Runner
 }
@@ -45,6 +45,10 @@ type AwaitableT interface {
 	//
 	// If the previous computation failed value is undefined.
 	//
+	// f may also take the Runner on which the result was created as an additional first parameter.
+	// Code that uses the runner passed to it (instead of the package-level functions such as New
+	// and NewR) never needs to look up the ambient runner.
+	//
 	// If f does not take a value then the previous computation's result is discarded.
 	//
 	// If f does not take an error then f is NOT called in the event that the previous computation
@@ -62,6 +62,7 @@ package async
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // R tracks the completion progress of an asynchronous computation.
@@ -74,9 +75,14 @@ func (R) Type() reflect.Type {
 	return reflect.TypeOf((*interface{})(nil)).Elem()
 }
 
-// NewR allocates a new result.
+// NewR allocates a new result on the ambient runner.
 func NewR() (R, S) {
-	r, s := NewBase()
+	return NewROn(GetCurrentRunner())
+}
+
+// NewROn allocates a new result on runner.  Unlike NewR it does not look up the ambient runner.
+func NewROn(runner Runner) (R, S) {
+	r, s := runner.NewResultT()
 	return R{r}, S{s}
 }
 
@@ -89,6 +95,22 @@ func New(f Func) R {
 	return GetCurrentRunner().New(f)
 }
 
+// NewWithPriority creates a new asynchronous computation that runs at priority p and returns its
+// associated result.
+func NewWithPriority(p Priority, f Func) R {
+	return GetCurrentRunner().NewWithPriority(p, f)
+}
+
+// Now returns the current time according to the current runner's clock.
+func Now() time.Time {
+	return GetCurrentRunner().Now()
+}
+
+// After returns a result that completes once d has elapsed on the current runner's clock.
+func After(d time.Duration) R {
+	return GetCurrentRunner().After(d)
+}
+
 // Done returns an unassociated already successfully completed void result.
 func Done() R {
 	return GetCurrentRunner().Done()
@@ -146,3 +168,8 @@ func (r S) Resolve(err error) {
 func (r S) Forward(next R) {
 	r.s.Forward(next.ResultT)
 }
+
+// Runner implements ResolverT.Runner() for void.
+func (r S) Runner() Runner {
+	return r.s.Runner()
+}
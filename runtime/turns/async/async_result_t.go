@@ -75,6 +75,9 @@ type ResolverT interface {
 	// WhenT schedules a function to be run when the result is completed.
 	// See WhenFuncT for the specifications for f.
 	WhenT(in, out, outR reflect.Type, f interface{}) ResultT
+
+	// Runner returns the runner on which the result was created.
+	Runner() Runner
 }
 
 // InternalUseOnlyGetResolver this method is for internal use only and should NEVER be called.
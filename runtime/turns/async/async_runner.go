@@ -15,15 +15,67 @@
 
 package async
 
+import (
+	"fmt"
+	"time"
+)
+
+// Priority determines the order in which runnable computations are executed.  Runnable
+// computations of higher priority are generally run before those of lower priority (subject to the
+// Runner's priority policy).  Computations of equal priority are always run in FIFO order.
+type Priority int
+
+const (
+	// PriorityLow is for bulk work that may be deferred in favor of other work.
+	PriorityLow Priority = iota
+
+	// PriorityNormal is the default priority of all computations.
+	PriorityNormal
+
+	// PriorityHigh is for latency-sensitive work (e.g. responding to a heartbeat).
+	PriorityHigh
+
+	// NumPriorities is the number of priority levels.
+	NumPriorities int = iota
+)
+
+// IsValid returns true if p is one of the defined priority levels.
+func (p Priority) IsValid() bool {
+	return (p >= PriorityLow) && (int(p) < NumPriorities)
+}
+
+// String implements fmt.Stringer
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "Low"
+	case PriorityNormal:
+		return "Normal"
+	case PriorityHigh:
+		return "High"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
 // Runner abstracts the ability to execute asynchronous computation.  Each computation is
 // associated with a result that can be used to monitor its completion.
 type Runner interface {
 	// New creates a new asynchronous computation and returns its associated result.
 	New(Func) R
 
+	// NewWithPriority creates a new asynchronous computation that runs at priority p and returns
+	// its associated result.
+	NewWithPriority(p Priority, f Func) R
+
 	// NewResultT creates a new unassociated untyped result and its resolver.
 	NewResultT() (ResultT, ResolverT)
 
 	// Done returns an unassociated already successfully completed void result.
 	Done() R
+
+	// Now returns the runner's current time.
+	Now() time.Time
+
+	// After returns a result that completes once d has elapsed on the runner's clock.
+	After(d time.Duration) R
 }
@@ -0,0 +1,177 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Package check systematically explores the orders in which I/O computations can complete.
+//
+// An actor's turns run deterministically given the order in which its I/O completions are
+// delivered.  Explore runs a test body repeatedly, once for every distinct order in which the
+// body's I/O completions could be delivered, and reports the first order (schedule) in which the
+// body fails.  A failing schedule can be replayed with Run.
+//
+// Exploration is stateless: each schedule re-executes the body from the start.  The body MUST
+// therefore be deterministic given a schedule, and every I/O computation it starts MUST complete
+// on its own (e.g. without waiting on a turn of the same actor): each choice is made only once all
+// outstanding I/O has completed, so the set of completions to choose from is well defined.  A body
+// whose I/O may not complete can set Config.Settle, in which case completions that don't arrive
+// within the settle time are left for a later choice (and exploration depends on timing).
+//
+// The number of schedules grows factorially with the number of concurrent I/O computations.
+// Config.Independent can describe completions whose delivery order doesn't matter (e.g. because
+// their continuations touch disjoint state) in which case only one order of each set of
+// independent completions is explored (sleep-set partial-order reduction).
+package check
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// ErrNondeterministic is returned if re-executing the body with the same schedule prefix does not
+// produce the same pending I/O completions.
+var ErrNondeterministic = errors.New("check: body is not deterministic for a fixed I/O schedule")
+
+// Config controls the exploration.
+type Config struct {
+	// MaxSchedules (if non-zero) bounds the number of schedules explored.
+	MaxSchedules int
+
+	// Settle (if non-zero) bounds the time to wait for outstanding I/O before each choice.  By
+	// default each choice waits for all outstanding I/O.  See turns.Manager.SetIOScheduler.
+	Settle time.Duration
+
+	// Independent (if not nil) returns true if delivering a then b is equivalent to delivering b then
+	// a.  Independent MUST be symmetric.
+	Independent func(a, b turns.IOCompletion) bool
+}
+
+// Schedule is the order in which I/O completions were delivered identified by their IO.
+type Schedule []int64
+
+// String implements fmt.Stringer.
+func (s Schedule) String() string {
+	return fmt.Sprint([]int64(s))
+}
+
+// Failure is returned when the body fails on some schedule.
+type Failure struct {
+	// Schedule is the schedule on which the body failed.
+	Schedule Schedule
+
+	// Err is the body's error.
+	Err error
+}
+
+// Error implements error.
+func (f *Failure) Error() string {
+	return fmt.Sprintf("check: failed with schedule %v: %v", f.Schedule, f.Err)
+}
+
+// Explore runs body once for each distinct schedule of its I/O completions (up to
+// config.MaxSchedules).  Returns the number of distinct schedules run and a *Failure for the first
+// schedule on which body failed (if any).  With partial-order reduction some executions may turn
+// out to be equivalent to ones already explored; they are not counted (though their failures are
+// still reported).
+func Explore(config Config, body async.Func) (int, error) {
+	x := &explorer{
+		config: config,
+	}
+	count := 0
+	for {
+		x.depth, x.err, x.redundant, x.tail = 0, nil, false, nil
+		err := actor.RunActorWith(func(m *turns.Manager) {
+			m.SetIOScheduler(x, config.Settle)
+		}, body)
+		if !x.redundant {
+			count++
+		}
+		if x.err == nil && x.depth < len(x.stack) {
+			x.err = ErrNondeterministic
+		}
+		if x.err != nil {
+			return count, x.err
+		}
+		if err != nil {
+			return count, &Failure{
+				Schedule: x.schedule(),
+				Err:      err,
+			}
+		}
+		if !x.backtrack() || (config.MaxSchedules > 0 && count >= config.MaxSchedules) {
+			return count, nil
+		}
+	}
+}
+
+// Run runs body once delivering its I/O completions in the order given by schedule.  Completions
+// beyond the end of schedule are delivered in the order they were started.
+func Run(config Config, schedule Schedule, body async.Func) error {
+	r := &replayer{
+		schedule: schedule,
+	}
+	err := actor.RunActorWith(func(m *turns.Manager) {
+		m.SetIOScheduler(r, config.Settle)
+	}, body)
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return &Failure{
+			Schedule: schedule,
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+// replayer is an IOScheduler that follows a fixed schedule.
+type replayer struct {
+	// schedule is the schedule to follow.
+	schedule Schedule
+
+	// next is the index of the next choice in schedule.
+	next int
+
+	// err is set if the schedule can't be followed.
+	err error
+}
+
+// Choose implements turns.IOScheduler.Choose().
+func (r *replayer) Choose(pending []turns.IOCompletion) int {
+	if r.next >= len(r.schedule) {
+		return 0
+	}
+	want := r.schedule[r.next]
+	r.next++
+	if i := find(pending, want); i >= 0 {
+		return i
+	}
+	r.err = ErrNondeterministic
+	return 0
+}
+
+// find returns the index of the completion with the given IO, or -1.
+func find(pending []turns.IOCompletion, io int64) int {
+	for i, p := range pending {
+		if p.IO == io {
+			return i
+		}
+	}
+	return -1
+}
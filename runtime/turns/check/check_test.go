@@ -0,0 +1,201 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package check_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/check"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// CheckSuite is the test suite for Explore.
+type CheckSuite struct {
+	test.Suite
+}
+
+// TestCheckSuite runs the test suite for Explore.
+func TestCheckSuite(t *testing.T) {
+	test.RunSuite(t, new(CheckSuite))
+}
+
+// concurrentIO returns a body that starts one I/O computation on each of the named sources and
+// calls verify with the order in which their completions were delivered.
+func concurrentIO(names []string, verify func(order []string) error) async.Func {
+	return func() async.R {
+		var order []string
+		all := async.Done()
+		for _, name := range names {
+			name := name
+			src := turns.NewNamedTurnSource(name)
+			r := async.When(src.New(func() error { return nil }), func() {
+				order = append(order, name)
+				src.Close()
+			})
+			prev := all
+			all = async.When(prev, func() async.R { return r })
+		}
+		return async.When(all, func() error {
+			return verify(order)
+		})
+	}
+}
+
+// Exhaustive verifies that every order of three completions is explored exactly once.
+func (t *CheckSuite) Exhaustive() {
+	seen := make(map[string]int)
+	count, err := check.Explore(check.Config{}, concurrentIO([]string{"a", "b", "c"},
+		func(order []string) error {
+			seen[fmt.Sprint(order)]++
+			return nil
+		}))
+	if err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if count != 6 || len(seen) != 6 {
+		t.Errorf("Expected all permutations.  Got: %d schedules %v, Want: 6", count, seen)
+	}
+	for order, n := range seen {
+		if n != 1 {
+			t.Errorf("Expected each order once.  Got: %s %d times, Want: 1", order, n)
+		}
+	}
+}
+
+// Independent verifies that only one order of independent completions is explored.
+func (t *CheckSuite) Independent() {
+	// a and b are independent of each other but not of c.
+	config := check.Config{
+		Independent: func(x, y turns.IOCompletion) bool {
+			return x.Source != "c" && y.Source != "c"
+		},
+	}
+	count, err := check.Explore(config, concurrentIO([]string{"a", "b", "c"},
+		func([]string) error {
+			return nil
+		}))
+	if err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+
+	// Swapping adjacent a and b gives an equivalent order so {abc, bac} and {cab, cba} are each
+	// explored once, as are acb and bca.
+	if count != 4 {
+		t.Errorf("Expected reduced exploration.  Got: %d, Want: 4", count)
+	}
+}
+
+// PruneRedundant verifies that nothing below a redundant choice point is explored.
+func (t *CheckSuite) PruneRedundant() {
+	config := check.Config{
+		Independent: func(x, y turns.IOCompletion) bool {
+			return true
+		},
+	}
+	ok := func([]string) error { return nil }
+	executions := 0
+	count, err := check.Explore(config, func() async.R {
+		executions++
+		return async.When(concurrentIO([]string{"a", "b"}, ok)(), func() async.R {
+			return concurrentIO([]string{"c", "d"}, ok)()
+		})
+	})
+	if err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+
+	// Only abcd is distinct.  abdc and ba are found to be redundant as they are explored, and the
+	// orders of {c, d} that would follow ba are not explored at all.
+	if count != 1 || executions != 3 {
+		t.Errorf("Expected pruned exploration.  Got: %d schedules in %d executions, Want: 1 in 3",
+			count, executions)
+	}
+}
+
+// SlowIO verifies that choices wait for outstanding I/O however long it takes.
+func (t *CheckSuite) SlowIO() {
+	seen := make(map[string]bool)
+	count, err := check.Explore(check.Config{}, func() async.R {
+		var order []string
+		a, b := turns.NewNamedTurnSource("a"), turns.NewNamedTurnSource("b")
+		ra := async.When(a.New(func() error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}), func() {
+			order = append(order, "a")
+			a.Close()
+		})
+		rb := async.When(b.New(func() error { return nil }), func() {
+			order = append(order, "b")
+			b.Close()
+		})
+		return async.When(ra, func() async.R {
+			return async.When(rb, func() {
+				seen[fmt.Sprint(order)] = true
+			})
+		})
+	})
+	if err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if count != 2 || len(seen) != 2 {
+		t.Errorf("Expected both orders.  Got: %d schedules %v, Want: 2", count, seen)
+	}
+}
+
+// FailingSchedule verifies that the schedule of a failing order is reported and can be replayed.
+func (t *CheckSuite) FailingSchedule() {
+	// The body assumes that b never completes before a.
+	body := concurrentIO([]string{"a", "b", "c"}, func(order []string) error {
+		for _, name := range order {
+			if name == "a" {
+				return nil
+			}
+			if name == "b" {
+				return fmt.Errorf("b before a: %v", order)
+			}
+		}
+		return nil
+	})
+
+	_, err := check.Explore(check.Config{}, body)
+	failure, ok := err.(*check.Failure)
+	if !ok {
+		t.Fatalf("Expected a failure.  Got: %v, Want: *check.Failure", err)
+	}
+
+	if err := check.Run(check.Config{}, failure.Schedule, body); err == nil {
+		t.Errorf("Expected the schedule to fail again.  Got: nil, Want: %v", failure)
+	}
+	if err := check.Run(check.Config{}, check.Schedule{1, 2, 3}, body); err != nil {
+		t.Errorf("Expected the schedule to succeed.  Got: %v, Want: nil", err)
+	}
+}
+
+// MaxSchedules verifies that exploration stops after the configured number of schedules.
+func (t *CheckSuite) MaxSchedules() {
+	count, err := check.Explore(check.Config{MaxSchedules: 2}, concurrentIO([]string{"a", "b", "c"},
+		func([]string) error {
+			return nil
+		}))
+	if err != nil || count != 2 {
+		t.Errorf("Expected bounded exploration.  Got: %d, %v, Want: 2, nil", count, err)
+	}
+}
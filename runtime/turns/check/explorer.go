@@ -0,0 +1,149 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package check
+
+import "github.com/prolang/drydock/runtime/turns/turns"
+
+// node is a choice point in the tree of schedules.
+type node struct {
+	// enabled are the completions that could be delivered at this point.
+	enabled []turns.IOCompletion
+
+	// sleep are the completions whose delivery at this point is known to lead only to schedules
+	// equivalent to ones already explored.
+	sleep []turns.IOCompletion
+
+	// done are the choices whose subtrees have been fully explored.
+	done []turns.IOCompletion
+
+	// chosen is the index in enabled of the choice currently being explored.
+	chosen int
+}
+
+// explorer is an IOScheduler that performs a depth-first search of the tree of schedules by
+// re-executing the body once per leaf.
+type explorer struct {
+	// config controls the exploration.
+	config Config
+
+	// stack is the path of choice points to the current leaf.
+	stack []*node
+
+	// depth is the number of choices made so far in the current execution.
+	depth int
+
+	// err is set if the body turns out to be nondeterministic.
+	err error
+
+	// redundant is set if the current execution is equivalent to one already explored.  No choice
+	// points are added once it is set.
+	redundant bool
+
+	// tail are the choices made after the current execution became redundant.
+	tail Schedule
+}
+
+// Choose implements turns.IOScheduler.Choose().
+func (x *explorer) Choose(pending []turns.IOCompletion) int {
+	d := x.depth
+	x.depth++
+
+	// Follow the current path as far as it goes.
+	if d < len(x.stack) {
+		n := x.stack[d]
+		i := find(pending, n.enabled[n.chosen].IO)
+		if i < 0 || len(pending) != len(n.enabled) {
+			x.err = ErrNondeterministic
+			return 0
+		}
+		return i
+	}
+
+	// Then extend it with new choice points.
+	if !x.redundant {
+		n := &node{
+			enabled: append([]turns.IOCompletion(nil), pending...),
+			sleep:   x.childSleep(d, pending),
+		}
+		if n.chosen = n.nextChoice(); n.chosen >= 0 {
+			x.stack = append(x.stack, n)
+			return n.chosen
+		}
+
+		// Everything is asleep so every continuation is equivalent to one already explored.
+		x.redundant = true
+	}
+
+	// A redundant execution still has to finish, but there is nothing below it to explore so no
+	// more choice points are added.
+	x.tail = append(x.tail, pending[0].IO)
+	return 0
+}
+
+// childSleep returns the sleep set of a new choice point at depth d with the given pending
+// completions.  A completion that was asleep (or already explored) in the parent stays asleep if
+// it is independent of the parent's choice.
+func (x *explorer) childSleep(d int, pending []turns.IOCompletion) []turns.IOCompletion {
+	if (d == 0) || (x.config.Independent == nil) {
+		return nil
+	}
+	parent := x.stack[d-1]
+	choice := parent.enabled[parent.chosen]
+
+	var sleep []turns.IOCompletion
+	for _, set := range [][]turns.IOCompletion{parent.sleep, parent.done} {
+		for _, s := range set {
+			if (s.IO != choice.IO) && (find(pending, s.IO) >= 0) && x.config.Independent(s, choice) {
+				sleep = append(sleep, s)
+			}
+		}
+	}
+	return sleep
+}
+
+// nextChoice returns the index of the first enabled completion that is neither asleep nor done,
+// or -1 if there is none.
+func (n *node) nextChoice() int {
+	for i, e := range n.enabled {
+		if (find(n.sleep, e.IO) < 0) && (find(n.done, e.IO) < 0) {
+			return i
+		}
+	}
+	return -1
+}
+
+// backtrack moves to the next unexplored leaf.  Returns false if the whole tree has been explored.
+func (x *explorer) backtrack() bool {
+	for len(x.stack) > 0 {
+		n := x.stack[len(x.stack)-1]
+		n.done = append(n.done, n.enabled[n.chosen])
+		if i := n.nextChoice(); i >= 0 {
+			n.chosen = i
+			return true
+		}
+		x.stack = x.stack[:len(x.stack)-1]
+	}
+	return false
+}
+
+// schedule returns the schedule of the current execution.
+func (x *explorer) schedule() Schedule {
+	s := make(Schedule, 0, x.depth)
+	for _, n := range x.stack[:x.depth-len(x.tail)] {
+		s = append(s, n.enabled[n.chosen].IO)
+	}
+	return append(s, x.tail...)
+}
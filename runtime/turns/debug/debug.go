@@ -0,0 +1,244 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Package debug serves the state of live actors over HTTP for local inspection.
+//
+// Actors opt in by registering their manager before it starts running turns:
+//
+//	h := actor.StartWith(func(m *turns.Manager) {
+//		m.EnableFlightRecorder("server", 0) // Optional: adds recent events.
+//		debug.Register("server", m)
+//	}, root)
+//	go debug.ListenAndServe("unix:/tmp/server.sock")
+//
+// The state of every registered actor can then be fetched with Fetch (or the drydock-inspect
+// command).  The recent events reported for an actor are those of its flight recorder: a
+// turns.Tracer streams its events to its writer and keeps none to report.  Each actor's state is
+// taken on its own thread (through a daemon Inbox), so an actor that is busy running a long turn is
+// reported as unresponsive rather than blocking the others.
+//
+// The endpoint exposes internal program state and has no authentication.  It SHOULD only be served
+// on localhost or a Unix socket.
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/turns"
+
+	log "github.com/golang/glog"
+)
+
+// Path is the path at which ListenAndServe serves the actors' state.
+const Path = "/debug/drydock/actors"
+
+// DefaultAddr is the address used by drydock-inspect if none is given.
+const DefaultAddr = "localhost:6061"
+
+// DefaultTimeout is how long the handler waits for each actor to report its state.
+const DefaultTimeout = time.Second
+
+// ActorState is the state of a registered actor.
+type ActorState struct {
+	// Name is the name under which the actor was registered.
+	Name string
+
+	// State is the state of the actor's manager (or nil if it could not be taken).
+	State *turns.ManagerState `json:",omitempty"`
+
+	// Error describes why the state could not be taken (e.g. the actor is busy).
+	Error string `json:",omitempty"`
+}
+
+// registration is a registered actor.
+type registration struct {
+	name    string
+	seq     int
+	manager *turns.Manager
+	inbox   *turns.Inbox
+}
+
+// registered are the registered actors.
+var registered = make(map[*registration]bool)
+
+// registeredSeq is the sequence number of the last registration.
+var registeredSeq int
+
+// registeredLock protects registered and registeredSeq.
+var registeredLock sync.Mutex
+
+// Register makes the state of m available under name until m is closed (which an actor does when
+// it exits).  Several actors may be registered under the same name.  The state is taken through a
+// daemon Inbox (see turns.Manager.NewDaemonInbox), so registering does not stop the manager from
+// reporting a DeadlockError.  Register causes the manager to track its I/O sources (see
+// turns.Manager.TrackSources).
+// THREADING: Register MUST be called on the manager's thread before it starts running turns.
+func Register(name string, m *turns.Manager) {
+	m.TrackSources()
+	r := &registration{
+		name:    name,
+		manager: m,
+		inbox:   m.NewDaemonInbox("debug"),
+	}
+	registeredLock.Lock()
+	registeredSeq++
+	r.seq = registeredSeq
+	registered[r] = true
+	registeredLock.Unlock()
+
+	// The registration is posted to by other threads so it is removed before the inbox is closed.
+	m.OnClose(func() {
+		registeredLock.Lock()
+		delete(registered, r)
+		registeredLock.Unlock()
+		r.inbox.Close()
+	})
+}
+
+// Inspect returns the state of every registered actor in registration order.  Actors that do not
+// report their state within timeout are reported with an Error.
+// THREADING: This method is multi-thread safe.  It MUST NOT be called by a registered actor.
+func Inspect(timeout time.Duration) []ActorState {
+	registeredLock.Lock()
+	registrations := make([]*registration, 0, len(registered))
+	for r := range registered {
+		registrations = append(registrations, r)
+	}
+	registeredLock.Unlock()
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].seq < registrations[j].seq
+	})
+
+	// Post to every actor before waiting on any so that they all report concurrently.
+	replies := make([]chan turns.ManagerState, len(registrations))
+	result := make([]ActorState, len(registrations))
+	for i, r := range registrations {
+		result[i].Name = r.name
+		reply := make(chan turns.ManagerState, 1)
+		if err := r.inbox.Post(func() {
+			reply <- r.manager.Inspect()
+		}); err != nil {
+			result[i].Error = err.Error()
+			continue
+		}
+		replies[i] = reply
+	}
+
+	// Every actor has had the full timeout to reply once the deadline has passed, so the remaining
+	// replies are then only polled.
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	expired := false
+	for i, reply := range replies {
+		if reply == nil {
+			continue
+		}
+		if !expired {
+			select {
+			case state := <-reply:
+				result[i].State = &state
+				continue
+			case <-deadline.C:
+				expired = true
+			}
+		}
+		select {
+		case state := <-reply:
+			result[i].State = &state
+		default:
+			result[i].Error = fmt.Sprintf("unresponsive for %v", timeout)
+		}
+	}
+	return result
+}
+
+// Handler returns a handler that serves the state of every registered actor as a JSON array of
+// ActorState.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := json.MarshalIndent(Inspect(DefaultTimeout), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(append(body, '\n')); err != nil {
+			log.Warningf("debug: failed to write actor state: %v", err)
+		}
+	})
+}
+
+// Listen listens on addr.  An addr of the form "unix:<path>" listens on a Unix socket, otherwise
+// addr is a TCP address (e.g. "localhost:6061").
+func Listen(addr string) (net.Listener, error) {
+	if path := strings.TrimPrefix(addr, "unix:"); path != addr {
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Serve serves Handler at Path on l.
+func Serve(l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	return http.Serve(l, mux)
+}
+
+// ListenAndServe listens on addr (see Listen) and serves Handler at Path.
+func ListenAndServe(addr string) error {
+	l, err := Listen(addr)
+	if err != nil {
+		return err
+	}
+	return Serve(l)
+}
+
+// Fetch retrieves the state of every actor registered with the process serving at addr (see
+// Listen).
+func Fetch(addr string, timeout time.Duration) ([]ActorState, error) {
+	client := &http.Client{Timeout: timeout}
+	url := "http://" + addr + Path
+	if path := strings.TrimPrefix(addr, "unix:"); path != addr {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		url = "http://unix" + Path
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	var actors []ActorState
+	if err := json.NewDecoder(resp.Body).Decode(&actors); err != nil {
+		return nil, err
+	}
+	return actors, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package debug_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/debug"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// DebugSuite is the test suite for the debug endpoint.
+type DebugSuite struct {
+	test.Suite
+}
+
+// TestDebugSuite runs the test suite for the debug endpoint.
+func TestDebugSuite(t *testing.T) {
+	test.RunSuite(t, new(DebugSuite))
+}
+
+// startBlocked starts an actor registered under name whose root waits for I/O from a source named
+// "blocked" until unblock is closed.  If busy is true then the I/O completion is run instead in a
+// turn that blocks the actor.
+func startBlocked(name string, unblock chan struct{}, busy bool) *actor.Handle {
+	return actor.StartWith(func(m *turns.Manager) {
+		m.EnableFlightRecorder(name, 0)
+		debug.Register(name, m)
+	}, func() async.R {
+		if busy {
+			return async.New(func() async.R {
+				<-unblock
+				return async.Done()
+			})
+		}
+		source := turns.NewNamedTurnSource("blocked")
+		return async.Finally(source.New(func() error {
+			<-unblock
+			return nil
+		}), source.Close)
+	})
+}
+
+// Fetch verifies that the state of a registered actor can be fetched over a Unix socket and that
+// the actor is unregistered when it exits.
+func (t *DebugSuite) Fetch() {
+	dir, err := ioutil.TempDir("", "debug")
+	if err != nil {
+		t.Fatalf("Expected temp dir.  Got: %v, Want: nil", err)
+	}
+	defer os.RemoveAll(dir)
+	addr := "unix:" + filepath.Join(dir, "debug.sock")
+	l, err := debug.Listen(addr)
+	if err != nil {
+		t.Fatalf("Expected to listen.  Got: %v, Want: nil", err)
+	}
+	defer l.Close()
+	go debug.Serve(l)
+
+	unblock := make(chan struct{})
+	h := startBlocked("fetched", unblock, false)
+	actors, err := debug.Fetch(addr, 10*time.Second)
+	if err != nil {
+		t.Fatalf("Expected fetch to succeed.  Got: %v, Want: nil", err)
+	}
+	if len(actors) != 1 || actors[0].Name != "fetched" || actors[0].State == nil {
+		t.Fatalf("Expected the registered actor.  Got: %+v, Want: fetched", actors)
+	}
+	state := actors[0].State
+	want := []string{"blocked", "debug", "inbox"}
+	if got := state.Sources; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected the registered sources.  Got: %v, Want: %v", got, want)
+	}
+	if state.PendingResults == 0 {
+		t.Errorf("Expected pending results.  Got: %v, Want: >0", state.PendingResults)
+	}
+	if len(state.RecentEvents) == 0 {
+		t.Errorf("Expected recent events.  Got: none, Want: some")
+	}
+
+	close(unblock)
+	if err := h.Wait(); err != nil {
+		t.Errorf("Expected actor to succeed.  Got: %v, Want: nil", err)
+	}
+	if got := debug.Inspect(time.Second); len(got) != 0 {
+		t.Errorf("Expected actor to be unregistered.  Got: %+v, Want: none", got)
+	}
+}
+
+// Unresponsive verifies that a busy actor is reported without blocking the caller.
+func (t *DebugSuite) Unresponsive() {
+	unblock := make(chan struct{})
+	h := startBlocked("busy", unblock, true)
+	defer h.Wait()
+	defer close(unblock)
+
+	got := debug.Inspect(50 * time.Millisecond)
+	if len(got) != 1 || got[0].State != nil || got[0].Error == "" {
+		t.Errorf("Expected the busy actor to be unresponsive.  Got: %+v, Want: error", got)
+	}
+}
+
+// Deadlock verifies that registering an actor does not stop it from reporting a deadlock.
+func (t *DebugSuite) Deadlock() {
+	err := actor.RunActorWith(func(m *turns.Manager) {
+		debug.Register("deadlocked", m)
+	}, func() async.R {
+		orphan, _ := async.NewR()
+		return async.When(orphan, func() {})
+	})
+	if _, ok := err.(*turns.DeadlockError); !ok {
+		t.Errorf("Expected deadlock.  Got: %v, Want: *DeadlockError", err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Package metrics exports the runtime metrics of turn managers via expvar and in the Prometheus
+// text exposition format.
+//
+// Metrics are collected by a Manager once enabled:
+//
+//	m := turns.NewManager(...)
+//	metrics.Publish("main", m.EnableMetrics())
+//	http.Handle("/metrics", metrics.Handler())
+//
+// All published metrics also appear under the "drydock.turns" expvar (e.g. at /debug/vars).
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// ExpvarName is the name of the expvar under which all published metrics appear.
+const ExpvarName = "drydock.turns"
+
+// published maps names to the published metrics.
+var published = make(map[string]*turns.Metrics)
+
+// publishedLock protects published.
+var publishedLock sync.Mutex
+
+// publishOnce registers the expvar the first time metrics are published.
+var publishOnce sync.Once
+
+// Publish makes m available to exporters under name, replacing any metrics already published under
+// that name.  Names typically identify the actor whose manager collected the metrics.
+func Publish(name string, m *turns.Metrics) {
+	publishOnce.Do(func() {
+		expvar.Publish(ExpvarName, expvar.Func(expvarValue))
+	})
+	publishedLock.Lock()
+	published[name] = m
+	publishedLock.Unlock()
+}
+
+// Unpublish removes the metrics published under name.
+func Unpublish(name string) {
+	publishedLock.Lock()
+	delete(published, name)
+	publishedLock.Unlock()
+}
+
+// entry is a published set of metrics.
+type entry struct {
+	name    string
+	metrics *turns.Metrics
+}
+
+// snapshot returns the published metrics sorted by name.
+func snapshot() []entry {
+	publishedLock.Lock()
+	defer publishedLock.Unlock()
+	result := make([]entry, 0, len(published))
+	for name, m := range published {
+		result = append(result, entry{name, m})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
+
+// histogramValue is the expvar representation of a histogram.
+type histogramValue struct {
+	Buckets map[string]int64
+	Count   int64
+	Sum     float64
+}
+
+// newHistogramValue converts a histogram to its expvar representation.
+func newHistogramValue(s turns.HistogramSnapshot) histogramValue {
+	v := histogramValue{
+		Buckets: make(map[string]int64, len(s.Counts)),
+		Count:   s.Count,
+		Sum:     s.Sum.Seconds(),
+	}
+	for i, c := range s.Counts {
+		v.Buckets[bucketLabel(s, i)] = c
+	}
+	return v
+}
+
+// expvarValue returns the value of the expvar: a map from name to that manager's metrics.
+func expvarValue() interface{} {
+	result := make(map[string]interface{})
+	for _, e := range snapshot() {
+		m := e.metrics
+		result[e.name] = map[string]interface{}{
+			"TurnsRun":         m.TurnsRun.Get(),
+			"QueueDepth":       m.QueueDepth.Get(),
+			"IOTurns":          m.IOTurns.Get(),
+			"IOTurnsBySource":  m.IOTurnsBySource(),
+			"SlowTurns":        m.SlowTurns.Get(),
+			"TurnDuration":     newHistogramValue(m.TurnDuration.Snapshot()),
+			"WaitDuration":     newHistogramValue(m.WaitDuration.Snapshot()),
+			"ResultsCreated":   m.ResultsCreated.Get(),
+			"ResultsResolved":  m.ResultsResolved.Get(),
+			"ResultsForwarded": m.ResultsForwarded.Get(),
+		}
+	}
+	return result
+}
+
+// bucketLabel returns the Prometheus "le" label of bucket i in seconds.
+func bucketLabel(s turns.HistogramSnapshot, i int) string {
+	if i == len(s.Bounds) {
+		return "+Inf"
+	}
+	return fmt.Sprint(s.Bounds[i].Seconds())
+}
+
+// quote escapes a label value.
+func quote(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return `"` + v + `"`
+}
+
+// WritePrometheus writes all published metrics to w in the Prometheus text exposition format.
+func WritePrometheus(w io.Writer) error {
+	entries := snapshot()
+	p := &printer{w: w}
+
+	p.family("drydock_turns_run_total", "counter", "Turns run.")
+	for _, e := range entries {
+		p.sample("drydock_turns_run_total", e.name, "", e.metrics.TurnsRun.Get())
+	}
+	p.family("drydock_turns_queue_depth", "gauge", "Turns waiting to run at the end of the last loop.")
+	for _, e := range entries {
+		p.sample("drydock_turns_queue_depth", e.name, "", e.metrics.QueueDepth.Get())
+	}
+	p.family("drydock_turns_io_total", "counter", "I/O completions ingested by source label.")
+	for _, e := range entries {
+		bySource := e.metrics.IOTurnsBySource()
+		sources := make([]string, 0, len(bySource))
+		for source := range bySource {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			p.sample("drydock_turns_io_total", e.name, ",source="+quote(source), bySource[source])
+		}
+	}
+	p.family("drydock_turns_slow_total", "counter", "Turns reported by the watchdog as running too long.")
+	for _, e := range entries {
+		p.sample("drydock_turns_slow_total", e.name, "", e.metrics.SlowTurns.Get())
+	}
+	p.family("drydock_turns_turn_duration_seconds", "histogram", "Time taken to run each turn.")
+	for _, e := range entries {
+		p.histogram("drydock_turns_turn_duration_seconds", e.name, e.metrics.TurnDuration.Snapshot())
+	}
+	p.family("drydock_turns_wait_duration_seconds", "histogram", "Time blocked waiting for I/O or timers.")
+	for _, e := range entries {
+		p.histogram("drydock_turns_wait_duration_seconds", e.name, e.metrics.WaitDuration.Snapshot())
+	}
+	p.family("drydock_turns_results_created_total", "counter", "Results created.")
+	for _, e := range entries {
+		p.sample("drydock_turns_results_created_total", e.name, "", e.metrics.ResultsCreated.Get())
+	}
+	p.family("drydock_turns_results_resolved_total", "counter", "Results resolved.")
+	for _, e := range entries {
+		p.sample("drydock_turns_results_resolved_total", e.name, "", e.metrics.ResultsResolved.Get())
+	}
+	p.family("drydock_turns_results_forwarded_total", "counter", "Results forwarded to other results.")
+	for _, e := range entries {
+		p.sample("drydock_turns_results_forwarded_total", e.name, "", e.metrics.ResultsForwarded.Get())
+	}
+	return p.err
+}
+
+// printer writes the text exposition format, remembering the first error.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes formatted output unless an error has already occurred.
+func (p *printer) printf(format string, a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, a...)
+	}
+}
+
+// family writes the HELP and TYPE lines of a metric family.
+func (p *printer) family(name, kind, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a single sample.  labels are additional labels (each preceded by a comma).
+func (p *printer) sample(name, actor, labels string, value int64) {
+	p.printf("%s{actor=%s%s} %d\n", name, quote(actor), labels, value)
+}
+
+// histogram writes the cumulative buckets, sum and count of a histogram.
+func (p *printer) histogram(name, actor string, s turns.HistogramSnapshot) {
+	cumulative := int64(0)
+	for i, c := range s.Counts {
+		cumulative += c
+		p.sample(name+"_bucket", actor, ",le="+quote(bucketLabel(s, i)), cumulative)
+	}
+	p.printf("%s_sum{actor=%s} %v\n", name, quote(actor), s.Sum.Seconds())
+	p.sample(name+"_count", actor, "", s.Count)
+}
+
+// Handler returns an http.Handler that serves all published metrics in the Prometheus text
+// exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w)
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/metrics"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// MetricsSuite is the test suite for the metrics exporters.
+type MetricsSuite struct {
+	test.Suite
+}
+
+// TestMetricsSuite runs the test suite for the metrics exporters.
+func TestMetricsSuite(t *testing.T) {
+	test.RunSuite(t, new(MetricsSuite))
+}
+
+// runWithMetrics runs root in a new actor and returns the metrics collected by its manager.
+func runWithMetrics(root async.Func) (*turns.Metrics, error) {
+	var m *turns.Metrics
+	err := actor.RunActorWith(func(manager *turns.Manager) {
+		m = manager.EnableMetrics()
+	}, root)
+	return m, err
+}
+
+// Results verifies that results created, resolved and forwarded are counted.
+func (t *MetricsSuite) Results() {
+	m, err := runWithMetrics(func() async.R {
+		r := async.New(func() async.R {
+			return async.Done()
+		})
+		return async.When(r, func() async.R {
+			return async.After(time.Millisecond)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if m.ResultsCreated.Get() == 0 || m.ResultsResolved.Get() == 0 || m.ResultsForwarded.Get() == 0 {
+		t.Errorf("Expected results to be counted.  Got: %d created, %d resolved, %d forwarded",
+			m.ResultsCreated.Get(), m.ResultsResolved.Get(), m.ResultsForwarded.Get())
+	}
+	if m.WaitDuration.Snapshot().Count == 0 {
+		t.Errorf("Expected time blocked on the timer.  Got: %v, Want: > 0", m.WaitDuration.Snapshot())
+	}
+}
+
+// Prometheus verifies the text exposition format served by Handler.
+func (t *MetricsSuite) Prometheus() {
+	m, err := runWithMetrics(func() async.R {
+		return async.New(func() async.R {
+			return async.Done()
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	metrics.Publish("prom", m)
+	defer metrics.Unpublish("prom")
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE drydock_turns_run_total counter\n",
+		"drydock_turns_run_total{actor=\"prom\"} ",
+		"drydock_turns_turn_duration_seconds_bucket{actor=\"prom\",le=\"+Inf\"} ",
+		"drydock_turns_turn_duration_seconds_count{actor=\"prom\"} ",
+		"drydock_turns_results_created_total{actor=\"prom\"} ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected sample.  Got: %v, Want: %q", body, want)
+		}
+	}
+}
+
+// Expvar verifies that published metrics appear in the expvar.
+func (t *MetricsSuite) Expvar() {
+	m, err := runWithMetrics(func() async.R {
+		return async.Done()
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	metrics.Publish("vars", m)
+	defer metrics.Unpublish("vars")
+
+	var value map[string]struct {
+		TurnsRun int64
+	}
+	v := expvar.Get(metrics.ExpvarName)
+	if v == nil {
+		t.Fatalf("Expected expvar.  Got: nil, Want: %v", metrics.ExpvarName)
+	}
+	if err := json.NewDecoder(bytes.NewBufferString(v.String())).Decode(&value); err != nil {
+		t.Fatalf("Expected JSON.  Got: %v, Want: nil", err)
+	}
+	if got, ok := value["vars"]; !ok || got.TurnsRun != m.TurnsRun.Get() {
+		t.Errorf("Expected published metrics.  Got: %+v, Want: %v turns", value, m.TurnsRun.Get())
+	}
+}
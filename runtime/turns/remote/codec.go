@@ -0,0 +1,113 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package remote
+
+// This file contains the pluggable serialization used to transmit messages between nodes.
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+)
+
+// Envelope is the unit of transmission between nodes.
+type Envelope struct {
+	// To is the address of the destination mailbox.
+	To actor.Address
+
+	// Message is the message being delivered.
+	Message actor.Message
+}
+
+// Encoder writes envelopes to a stream.
+type Encoder interface {
+	// Encode writes a single envelope to the stream.
+	Encode(e *Envelope) error
+}
+
+// Decoder reads envelopes from a stream.
+type Decoder interface {
+	// Decode reads a single envelope from the stream.
+	Decode(e *Envelope) error
+}
+
+// Codec serializes envelopes to and from a connection.  A codec defines the set of message types
+// that may be sent between nodes.
+type Codec interface {
+	// NewEncoder returns an Encoder that writes to w.
+	NewEncoder(w io.Writer) Encoder
+
+	// NewDecoder returns a Decoder that reads from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// GobCodec serializes messages with encoding/gob.  Messages are received with the same concrete
+// type with which they were sent.
+//
+// REQUIRES: every concrete message type other than the predeclared types MUST be registered with
+// gob.Register in both the sending and receiving process.
+type GobCodec struct{}
+
+// NewEncoder implements Codec.NewEncoder().
+func (GobCodec) NewEncoder(w io.Writer) Encoder {
+	return streamEncoder{gob.NewEncoder(w)}
+}
+
+// NewDecoder implements Codec.NewDecoder().
+func (GobCodec) NewDecoder(r io.Reader) Decoder {
+	return streamDecoder{gob.NewDecoder(r)}
+}
+
+// JSONCodec serializes messages with encoding/json.  Messages are received as generic JSON values
+// (i.e. bool, float64, string, []interface{}, map[string]interface{} or nil) regardless of the
+// concrete type with which they were sent.
+type JSONCodec struct{}
+
+// NewEncoder implements Codec.NewEncoder().
+func (JSONCodec) NewEncoder(w io.Writer) Encoder {
+	return streamEncoder{json.NewEncoder(w)}
+}
+
+// NewDecoder implements Codec.NewDecoder().
+func (JSONCodec) NewDecoder(r io.Reader) Decoder {
+	return streamDecoder{json.NewDecoder(r)}
+}
+
+// streamEncoder adapts the standard library's stream encoders to Encoder.
+type streamEncoder struct {
+	enc interface {
+		Encode(v interface{}) error
+	}
+}
+
+// Encode implements Encoder.Encode().
+func (e streamEncoder) Encode(env *Envelope) error {
+	return e.enc.Encode(env)
+}
+
+// streamDecoder adapts the standard library's stream decoders to Decoder.
+type streamDecoder struct {
+	dec interface {
+		Decode(v interface{}) error
+	}
+}
+
+// Decode implements Decoder.Decode().
+func (d streamDecoder) Decode(env *Envelope) error {
+	return d.dec.Decode(env)
+}
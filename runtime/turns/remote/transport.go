@@ -0,0 +1,457 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Package remote allows actors in different processes on the same machine to exchange messages
+// over TCP loopback or Unix domain sockets using the same Address and Send API as actors within a
+// process.
+//
+// Each process is a node with a unique name.  A Transport listens for connections from other
+// nodes and delivers the messages it receives to the local mailboxes they are addressed to.  A
+// Transport also registers itself (via Connect) as the actor.Router for other nodes so that
+// actor.Send can reach their mailboxes.
+//
+// Delivery Semantics:
+//
+// Remote delivery is AT-MOST-ONCE.  Each message is written to a connection at most once and is
+// never retransmitted.  A successful Send to a remote address means only that the message was
+// written to the connection, NOT that it was accepted by the remote mailbox.  If the connection
+// fails the message may or may not have been delivered.  A failed Send means that the message was
+// never written.  Messages sent to the same node are written in the order they were sent.
+//
+// Connection Supervision:
+//
+// Connections to other nodes are established on demand.  A broken connection is discarded and
+// re-established (with exponential backoff between attempts) by the next message sent to that
+// node.  If a connection cannot be established after DialAttempts attempts then the message fails.
+package remote
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+
+	log "github.com/golang/glog"
+)
+
+// ErrTransportClosed is returned for messages sent through a Transport that has been closed.
+var ErrTransportClosed = errors.New("transport closed")
+
+// Default supervision parameters.
+const (
+	DefaultDialAttempts = 3
+	DefaultMinBackoff   = 10 * time.Millisecond
+	DefaultMaxBackoff   = time.Second
+)
+
+// TransportConfig configures a new Transport.
+type TransportConfig struct {
+	// Node is the name of the local node.  Messages received for other nodes are discarded.
+	Node string
+
+	// Codec serializes messages.  All nodes MUST use the same codec.
+	Codec Codec
+
+	// DialAttempts is the number of consecutive attempts made to establish a connection before a
+	// message fails.  Zero means DefaultDialAttempts.
+	DialAttempts int
+
+	// MinBackoff is the delay after the first failed attempt.  Zero means DefaultMinBackoff.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the upper bound on the delay between attempts.  Zero means DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// Transport connects the local node to other nodes.  A Transport is an async.Source owned by the
+// actor that created it: the results of its I/O (e.g. Connect) are completed on that actor.
+type Transport struct {
+	// config is the immutable configuration of the transport.
+	config TransportConfig
+
+	// source is the I/O source on which the transport's results are completed.
+	source async.Source
+
+	// lock protects the fields below.
+	lock sync.Mutex
+
+	// closed is true once the transport has been closed.
+	closed bool
+
+	// listeners are the listeners accepting connections from other nodes.
+	listeners []net.Listener
+
+	// inbound are the connections accepted from other nodes.
+	inbound map[net.Conn]struct{}
+
+	// peers maps node names to the outbound connection to that node.
+	peers map[string]*peer
+}
+
+// NewTransport creates a new transport owned by the current actor.
+// REQUIRES: the caller must call Close when the transport is no longer needed.
+func NewTransport(config TransportConfig) *Transport {
+	assert.True(config.Codec != nil, "A transport requires a codec.")
+	if config.DialAttempts == 0 {
+		config.DialAttempts = DefaultDialAttempts
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return &Transport{
+		config:  config,
+		source:  turns.NewTurnSource(),
+		inbound: make(map[net.Conn]struct{}),
+		peers:   make(map[string]*peer),
+	}
+}
+
+// New implements async.Source.New().
+func (t *Transport) New(f async.IOFunc) async.R {
+	return t.source.New(f)
+}
+
+// Listen accepts connections from other nodes on the given network ("tcp" or "unix") and
+// address.  Returns the address actually listened on.
+func (t *Transport) Listen(network, address string) (net.Addr, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		l.Close()
+		return nil, ErrTransportClosed
+	}
+	t.listeners = append(t.listeners, l)
+	t.lock.Unlock()
+
+	go t.accept(l)
+	return l.Addr(), nil
+}
+
+// Connect registers this transport as the router for messages sent to node, which listens on the
+// given network and address.  The returned result is resolved once an initial connection has
+// been established.  If the initial connection fails then the route remains registered and
+// connecting is retried by the next message sent to node.
+func (t *Transport) Connect(node, network, address string) async.R {
+	p := &peer{
+		t:       t,
+		node:    node,
+		network: network,
+		address: address,
+	}
+	p.ready = sync.NewCond(&p.lock)
+
+	t.lock.Lock()
+	assert.True(!t.closed, "Cannot connect a closed transport.")
+	old := t.peers[node]
+	t.peers[node] = p
+	t.lock.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	go p.run()
+	actor.RegisterRouter(node, p)
+
+	return t.New(func() error {
+		p.connLock.Lock()
+		defer p.connLock.Unlock()
+		return p.connect()
+	})
+}
+
+// IsConnected returns true if there is currently an established connection to node.
+func (t *Transport) IsConnected(node string) bool {
+	t.lock.Lock()
+	p := t.peers[node]
+	t.lock.Unlock()
+	if p == nil {
+		return false
+	}
+
+	p.connLock.Lock()
+	defer p.connLock.Unlock()
+	return p.conn != nil
+}
+
+// Close implements async.Source.Close().  Close stops listening, closes all connections and
+// unregisters all routes.  Messages not yet written fail with ErrTransportClosed.
+//
+// WARNING: Any outstanding Connect will NOT complete its result.  The caller is responsible for
+// waiting for any pending Connect to complete *before* calling Close().
+func (t *Transport) Close() {
+	t.lock.Lock()
+	t.closed = true
+	listeners, inbound, peers := t.listeners, t.inbound, t.peers
+	t.listeners, t.inbound, t.peers = nil, nil, nil
+	t.lock.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+	for conn := range inbound {
+		conn.Close()
+	}
+	for _, p := range peers {
+		p.close()
+	}
+	t.source.Close()
+}
+
+// accept accepts connections from other nodes until the listener is closed.
+func (t *Transport) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.V(1).Infof("%s: stopped accepting on %v: %v", t.config.Node, l.Addr(), err)
+			return
+		}
+
+		t.lock.Lock()
+		if t.closed {
+			t.lock.Unlock()
+			conn.Close()
+			return
+		}
+		t.inbound[conn] = struct{}{}
+		t.lock.Unlock()
+
+		go t.serve(conn)
+	}
+}
+
+// serve delivers the messages received on an inbound connection until it is closed.
+func (t *Transport) serve(conn net.Conn) {
+	defer func() {
+		t.lock.Lock()
+		if t.inbound != nil {
+			delete(t.inbound, conn)
+		}
+		t.lock.Unlock()
+		conn.Close()
+	}()
+
+	dec := t.config.Codec.NewDecoder(conn)
+	for {
+		var env Envelope
+		if err := dec.Decode(&env); err != nil {
+			if err != io.EOF {
+				log.V(1).Infof("%s: inbound connection %v failed: %v", t.config.Node, conn.RemoteAddr(),
+					err)
+			}
+			return
+		}
+		if env.To.Node != t.config.Node {
+			log.Warningf("%s: discarding message for node %q: %v", t.config.Node, env.To.Node, env.To)
+			continue
+		}
+
+		// Undeliverable messages are handed to the dead letter sink by Deliver.
+		actor.Deliver(env.To, env.Message)
+	}
+}
+
+// peer is the outbound connection to another node.  peer implements actor.Router.
+type peer struct {
+	// t is the transport that owns the peer.
+	t *Transport
+
+	// node is the name of the remote node.
+	node string
+
+	// network and address are where the remote node listens.
+	network, address string
+
+	// lock protects the fields below.
+	lock sync.Mutex
+
+	// ready is signalled when a message is queued or the peer is closed.
+	ready *sync.Cond
+
+	// closed is true once the peer has been closed.
+	closed bool
+
+	// queue is the outbound messages not yet written in FIFO order.
+	queue []*outbound
+
+	// connLock protects the fields below.
+	connLock sync.Mutex
+
+	// conn is the established connection if any.
+	conn net.Conn
+
+	// enc is the encoder writing to conn.
+	enc Encoder
+}
+
+// outbound is a message waiting to be written.
+type outbound struct {
+	// env is the message and its destination.
+	env Envelope
+
+	// done receives the outcome once the message has been written (or not).
+	done chan error
+}
+
+// Route implements actor.Router.Route().
+func (p *peer) Route(to actor.Address, msg actor.Message) <-chan error {
+	o := &outbound{
+		env: Envelope{
+			To:      to,
+			Message: msg,
+		},
+		done: make(chan error, 1),
+	}
+
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		o.done <- ErrTransportClosed
+		return o.done
+	}
+	p.queue = append(p.queue, o)
+	p.ready.Signal()
+	p.lock.Unlock()
+	return o.done
+}
+
+// run writes queued messages in FIFO order until the peer is closed.
+func (p *peer) run() {
+	for {
+		p.lock.Lock()
+		for len(p.queue) == 0 && !p.closed {
+			p.ready.Wait()
+		}
+		if p.closed {
+			queue := p.queue
+			p.queue = nil
+			p.lock.Unlock()
+			for _, o := range queue {
+				o.done <- ErrTransportClosed
+			}
+			return
+		}
+		o := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.lock.Unlock()
+
+		o.done <- p.write(&o.env)
+	}
+}
+
+// write writes a single message to the connection, establishing one first if necessary.  The
+// message is NEVER retried once written to a connection (even if the write fails).
+func (p *peer) write(env *Envelope) error {
+	p.connLock.Lock()
+	defer p.connLock.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	if err := p.enc.Encode(env); err != nil {
+		log.V(1).Infof("%s: connection to %s failed: %v", p.t.config.Node, p.node, err)
+		p.conn.Close()
+		p.conn, p.enc = nil, nil
+		return err
+	}
+	return nil
+}
+
+// connect establishes a connection to the remote node with exponential backoff between attempts.
+// REQUIRES: connLock is held.
+func (p *peer) connect() error {
+	if p.conn != nil {
+		return nil
+	}
+
+	backoff := p.t.config.MinBackoff
+	var err error
+	for attempt := 0; attempt < p.t.config.DialAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > p.t.config.MaxBackoff {
+				backoff = p.t.config.MaxBackoff
+			}
+		}
+		if p.isClosed() {
+			return ErrTransportClosed
+		}
+
+		var conn net.Conn
+		if conn, err = net.Dial(p.network, p.address); err == nil {
+			log.V(1).Infof("%s: connected to %s at %s", p.t.config.Node, p.node, p.address)
+			p.conn, p.enc = conn, p.t.config.Codec.NewEncoder(conn)
+			go p.watch(conn)
+			return nil
+		}
+		log.V(1).Infof("%s: connecting to %s failed: %v", p.t.config.Node, p.node, err)
+	}
+	return err
+}
+
+// watch discards the connection as soon as the remote node closes it so that the next message
+// reconnects instead of writing to a dead connection.
+func (p *peer) watch(conn net.Conn) {
+	io.Copy(ioutil.Discard, conn)
+
+	p.connLock.Lock()
+	if p.conn == conn {
+		p.conn, p.enc = nil, nil
+	}
+	p.connLock.Unlock()
+	conn.Close()
+}
+
+// isClosed returns true if the peer has been closed.
+func (p *peer) isClosed() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.closed
+}
+
+// close unregisters the peer, fails any messages not yet written and closes the connection.
+func (p *peer) close() {
+	actor.UnregisterRouter(p.node, p)
+
+	p.lock.Lock()
+	p.closed = true
+	p.ready.Broadcast()
+	p.lock.Unlock()
+
+	p.connLock.Lock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.enc = nil, nil
+	}
+	p.connLock.Unlock()
+}
@@ -0,0 +1,277 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package remote_test
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/actor"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/remote"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// TransportSuite is the test suite for Transport.
+type TransportSuite struct {
+	test.Suite
+}
+
+// TestTransportSuite runs the test suite for Transport.
+func TestTransportSuite(t *testing.T) {
+	test.RunSuite(t, new(TransportSuite))
+}
+
+// ping is a user-defined message type.
+type ping struct {
+	Seq  int
+	From actor.Address
+}
+
+func init() {
+	gob.Register(ping{})
+}
+
+// nodes is a pair of transports connected over a socket.  Both nodes live in the test process so
+// messages sent to node "b" are delivered to mailboxes in this process.
+type nodes struct {
+	a, b *remote.Transport
+}
+
+// newNodes creates a pair of nodes where "a" routes messages to "b" over the given network.
+func newNodes(network, address string, codec remote.Codec) (*nodes, async.R) {
+	n := &nodes{
+		a: remote.NewTransport(remote.TransportConfig{
+			Node:  "a",
+			Codec: codec,
+		}),
+		b: remote.NewTransport(remote.TransportConfig{
+			Node:  "b",
+			Codec: codec,
+		}),
+	}
+	addr, err := n.b.Listen(network, address)
+	if err != nil {
+		return n, async.NewError(err)
+	}
+	return n, n.a.Connect("b", network, addr.String())
+}
+
+// close closes both nodes.
+func (n *nodes) close() {
+	n.a.Close()
+	n.b.Close()
+}
+
+// socketPath returns a path for a unix domain socket in a fresh temporary directory.
+func socketPath() string {
+	dir, err := ioutil.TempDir("", "drydock")
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(dir, "node.sock")
+}
+
+// roundTrip sends a message to the mailbox named inbox on node "b" and verifies that it is
+// received unchanged.
+func roundTrip(network, address string, codec remote.Codec, msg actor.Message) async.R {
+	n, connected := newNodes(network, address, codec)
+	inbox, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	to := actor.Address{Node: "b", Name: inbox.Address().Name}
+
+	r := async.When(connected, func() async.R {
+		return actor.Send(to, msg)
+	})
+	r = async.When(r, func() async.R {
+		return async.When(inbox.Receive(), func(got actor.Message) error {
+			if got != msg {
+				return fmt.Errorf("Expected message.  Got: %#v, Want: %#v", got, msg)
+			}
+			return nil
+		})
+	})
+	return async.Finally(r, func() {
+		inbox.Close()
+		n.close()
+	})
+}
+
+// TCPGob verifies that messages are delivered over TCP loopback with the gob codec.
+func (t *TransportSuite) TCPGob() async.R {
+	return roundTrip("tcp", "127.0.0.1:0", remote.GobCodec{}, ping{Seq: 1})
+}
+
+// UnixJSON verifies that messages are delivered over a Unix domain socket with the JSON codec.
+func (t *TransportSuite) UnixJSON() async.R {
+	path := socketPath()
+	r := roundTrip("unix", path, remote.JSONCodec{}, "hello")
+	return async.Finally(r, func() {
+		os.RemoveAll(filepath.Dir(path))
+	})
+}
+
+// FIFO verifies that messages sent to a node are delivered in the order they were sent.
+func (t *TransportSuite) FIFO() async.R {
+	n, connected := newNodes("tcp", "127.0.0.1:0", remote.GobCodec{})
+	inbox, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	to := actor.Address{Node: "b", Name: inbox.Address().Name}
+
+	const count = 100
+	r := async.When(connected, func() async.R {
+		var last async.R
+		for i := 0; i < count; i++ {
+			last = actor.Send(to, ping{Seq: i})
+		}
+		return last
+	})
+
+	var receive func(i int) async.R
+	receive = func(i int) async.R {
+		if i == count {
+			return async.Done()
+		}
+		return async.When(inbox.Receive(), func(got actor.Message) async.R {
+			if seq := got.(ping).Seq; seq != i {
+				return async.NewErrorf("Expected FIFO.  Got: %d, Want: %d", seq, i)
+			}
+			return receive(i + 1)
+		})
+	}
+	r = async.When(r, func() async.R {
+		return receive(0)
+	})
+	return async.Finally(r, func() {
+		inbox.Close()
+		n.close()
+	})
+}
+
+// UnknownNode verifies that messages sent to a node without a route fail.
+func (t *TransportSuite) UnknownNode() async.R {
+	to := actor.Address{Node: "nowhere", Name: "nobody"}
+	return async.When(actor.Send(to, "lost"), func(err error) error {
+		if err != actor.ErrUnknownNode {
+			return fmt.Errorf("Expected unknown node.  Got: %v, Want: %v", err, actor.ErrUnknownNode)
+		}
+		return nil
+	})
+}
+
+// PeerDown verifies that connecting to (and sending to) a node that is not listening fails after
+// the configured number of attempts.
+func (t *TransportSuite) PeerDown() async.R {
+	path := socketPath()
+	a := remote.NewTransport(remote.TransportConfig{
+		Node:         "a",
+		Codec:        remote.GobCodec{},
+		DialAttempts: 2,
+		MinBackoff:   time.Millisecond,
+	})
+
+	r := async.When(a.Connect("b", "unix", path), func(err error) async.R {
+		if err == nil {
+			return async.NewErrorf("Expected connect to fail.  Got: nil, Want: non-nil")
+		}
+		return actor.Send(actor.Address{Node: "b", Name: "nobody"}, "lost")
+	})
+	r = async.When(r, func(err error) error {
+		if err == nil {
+			return fmt.Errorf("Expected send to fail.  Got: nil, Want: non-nil")
+		}
+		return nil
+	})
+	return async.Finally(r, func() {
+		a.Close()
+		os.RemoveAll(filepath.Dir(path))
+	})
+}
+
+// AtMostOnce verifies that a message sent while a node is down is never delivered, and that the
+// connection is re-established once the node is back.
+func (t *TransportSuite) AtMostOnce() async.R {
+	path := socketPath()
+	config := remote.TransportConfig{
+		Node:         "b",
+		Codec:        remote.GobCodec{},
+		DialAttempts: 2,
+		MinBackoff:   time.Millisecond,
+	}
+	a := remote.NewTransport(remote.TransportConfig{
+		Node:         "a",
+		Codec:        remote.GobCodec{},
+		DialAttempts: 2,
+		MinBackoff:   time.Millisecond,
+	})
+	b := remote.NewTransport(config)
+	if _, err := b.Listen("unix", path); err != nil {
+		return async.NewError(err)
+	}
+	inbox, err := actor.NewMailbox("", actor.MailboxConfig{})
+	if err != nil {
+		return async.NewError(err)
+	}
+	to := actor.Address{Node: "b", Name: inbox.Address().Name}
+
+	// Take node "b" down and wait for "a" to notice.
+	var b2 *remote.Transport
+	r := async.When(a.Connect("b", "unix", path), func() async.R {
+		b.Close()
+		for a.IsConnected("b") {
+			time.Sleep(time.Millisecond)
+		}
+		return actor.Send(to, ping{Seq: 1})
+	})
+
+	// Bring node "b" back up.  The message sent while it was down MUST NOT be delivered.
+	r = async.When(r, func(err error) async.R {
+		if err == nil {
+			return async.NewErrorf("Expected send to fail.  Got: nil, Want: non-nil")
+		}
+		os.Remove(path)
+		b2 = remote.NewTransport(config)
+		if _, err := b2.Listen("unix", path); err != nil {
+			return async.NewError(err)
+		}
+		return actor.Send(to, ping{Seq: 2})
+	})
+	r = async.When(r, func() async.R {
+		return async.When(inbox.Receive(), func(got actor.Message) error {
+			if seq := got.(ping).Seq; seq != 2 {
+				return fmt.Errorf("Expected only the second message.  Got: %d, Want: 2", seq)
+			}
+			return nil
+		})
+	})
+	return async.Finally(r, func() {
+		inbox.Close()
+		a.Close()
+		if b2 != nil {
+			b2.Close()
+		}
+		os.RemoveAll(filepath.Dir(path))
+	})
+}
@@ -0,0 +1,560 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+// Package sim runs many actors in a single process on a simulated scheduler with virtual time and
+// a simulated network between them.  It is used to test distributed protocols built on drydock.
+//
+// Every source of nondeterminism in a simulation (the order in which nodes run, message latency,
+// message loss and any randomness used by the nodes themselves) is derived from a single seed.
+// Running a simulation twice with the same seed and the same code produces exactly the same
+// execution, so a failing seed can be replayed to debug the failure.
+//
+// Each node's manager uses the simulation's virtual clock, so async.Now and async.After read and
+// wait for virtual time.  Simulated nodes MUST NOT perform real I/O (e.g. through an async.Source),
+// read the wall clock, or use any randomness other than Node.Rand.  Doing so makes the simulation
+// nondeterministic.
+package sim
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+
+	log "github.com/golang/glog"
+)
+
+// Config configures a simulation.
+type Config struct {
+	// Seed determines every random choice made by the simulation.
+	Seed int64
+
+	// MinLatency and MaxLatency bound the uniformly distributed delay of each message.  Messages
+	// with different delays may be delivered out of order.
+	MinLatency, MaxLatency time.Duration
+
+	// DropRate is the probability in [0, 1] that any given message is lost.
+	DropRate float64
+
+	// MaxTime bounds the virtual duration of the simulation.  Zero means unbounded.
+	MaxTime time.Duration
+}
+
+// Packet is a message delivered by the simulated network.
+type Packet struct {
+	// From is the name of the sending node.
+	From string
+
+	// To is the name of the receiving node.
+	To string
+
+	// Message is the payload.
+	Message interface{}
+}
+
+// PacketR tracks the completion progress of a Receive.
+type PacketR struct {
+	async.ResultT
+}
+
+// Type implements AwaitableT.Type().
+func (PacketR) Type() reflect.Type {
+	return reflect.TypeOf((*Packet)(nil)).Elem()
+}
+
+// Simulation is a set of nodes sharing a simulated scheduler, clock and network.
+type Simulation struct {
+	// config is the immutable configuration of the simulation.
+	config Config
+
+	// rand is the source of all randomness in the simulation.
+	rand *rand.Rand
+
+	// now is the current virtual time measured from the start of the simulation.
+	now time.Duration
+
+	// seq orders events scheduled for the same virtual time in the order they were scheduled.
+	seq int64
+
+	// events is the timeline of pending events.
+	events eventQueue
+
+	// nodes are all nodes in the order they were spawned.
+	nodes []*Node
+
+	// byName maps node names to nodes.
+	byName map[string]*Node
+
+	// partition maps node names to partition groups.  Nodes in different groups cannot communicate.
+	// Nodes not in the map are in group 0.
+	partition map[string]int
+
+	// partitions is the number of partition groups ever created.  Each group gets a new id so that
+	// groups created at different times are never merged.
+	partitions int
+
+	// failure is the first failure of any node.
+	failure error
+
+	// history is a log of every scheduling and network decision.
+	history []string
+}
+
+// New creates a new empty simulation.
+func New(config Config) *Simulation {
+	assert.True(config.MinLatency <= config.MaxLatency, "MinLatency MUST NOT exceed MaxLatency")
+	assert.True(config.DropRate >= 0 && config.DropRate <= 1, "DropRate MUST be in [0, 1]")
+
+	return &Simulation{
+		config:    config,
+		rand:      rand.New(rand.NewSource(config.Seed)),
+		byName:    make(map[string]*Node),
+		partition: make(map[string]int),
+	}
+}
+
+// Now returns the current virtual time measured from the start of the simulation.
+func (s *Simulation) Now() time.Duration {
+	return s.now
+}
+
+// History returns the log of every scheduling and network decision made so far.  Two runs with
+// the same seed produce identical histories.
+func (s *Simulation) History() []string {
+	return s.history
+}
+
+// Spawn creates a new node that runs main.  The node is finished when the result returned by main
+// is resolved.  If it fails the simulation fails.
+func (s *Simulation) Spawn(name string, main func(n *Node) async.R) *Node {
+	_, exists := s.byName[name]
+	assert.True(!exists, "Node names MUST be unique: %s", name)
+
+	manager := turns.NewManager(turns.NewUniqueIDGenerator())
+	manager.SetClock(clock{s})
+	n := &Node{
+		sim:     s,
+		name:    name,
+		manager: manager,
+		runner:  turns.NewTurnRunner(manager),
+		rand:    rand.New(rand.NewSource(s.rand.Int63())),
+	}
+	s.nodes = append(s.nodes, n)
+	s.byName[name] = n
+
+	manager.NewTurn("Main", func() {
+		async.When(main(n), func(err error) {
+			n.finished = true
+			if err != nil && s.failure == nil {
+				s.failure = fmt.Errorf("sim: seed %d: node %s failed at %v: %v", s.config.Seed, name,
+					s.now, err)
+			}
+		})
+	})
+	return n
+}
+
+// At schedules f to run at virtual time t (or immediately if t has passed).  It is typically used
+// to inject faults (e.g. Partition, Heal or Crash) at a chosen point in the simulation.
+func (s *Simulation) At(t time.Duration, f func()) {
+	if t < s.now {
+		t = s.now
+	}
+	s.schedule(t, f)
+}
+
+// Partition splits the network so that the named nodes can communicate only among themselves.
+// Messages already in flight across the partition are lost when they arrive.
+func (s *Simulation) Partition(group ...string) {
+	s.partitions++
+	for _, name := range group {
+		s.partition[name] = s.partitions
+	}
+	s.record("partition %v", group)
+}
+
+// Heal removes all partitions.
+func (s *Simulation) Heal() {
+	s.partition = make(map[string]int)
+	s.record("heal")
+}
+
+// Crash stops a node.  Its pending turns never run, messages sent to it are lost and it is
+// considered finished.
+func (s *Simulation) Crash(name string) {
+	n := s.byName[name]
+	assert.True(n != nil, "Unknown node: %s", name)
+
+	n.crashed, n.finished = true, true
+	s.record("crash %s", name)
+}
+
+// DeadlockError is returned by Run when no further progress is possible but some nodes have not
+// finished.
+type DeadlockError struct {
+	// Seed is the seed of the simulation.
+	Seed int64
+
+	// At is the virtual time at which the simulation deadlocked.
+	At time.Duration
+
+	// Nodes are the names of the unfinished nodes in the order they were spawned.
+	Nodes []string
+}
+
+// Error implements error.Error().
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("sim: seed %d: deadlock at %v: nodes %v are blocked", e.Seed, e.At, e.Nodes)
+}
+
+// Run runs the simulation until every node has finished, no further progress is possible, or
+// MaxTime is reached.  Returns the first node failure (which includes the seed) if any, or a
+// DeadlockError if no further progress is possible but some nodes have not finished.
+func (s *Simulation) Run() error {
+	var current *Node
+	var release async.ReleaseFunc
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	for s.failure == nil {
+		// Run one turn from a randomly chosen runnable node.
+		n := s.step(func(n *Node) {
+			if n != current {
+				if release != nil {
+					release()
+				}
+				current, release = n, async.SetAmbientRunner(n.runner)
+			}
+		})
+		if n != nil {
+			continue
+		}
+
+		// Every node is idle so advance virtual time to the next event or timer.  Events are applied
+		// before timers with the same deadline.
+		if s.allFinished() {
+			break
+		}
+		timer, pending := s.nextTimer()
+		if (s.events.Len() == 0) && !pending {
+			s.record("deadlock")
+			return s.deadlock()
+		}
+		at := timer
+		if (s.events.Len() > 0) && (!pending || s.events[0].at <= timer) {
+			at = s.events[0].at
+		}
+		if s.config.MaxTime != 0 && at > s.config.MaxTime {
+			s.record("max time reached")
+			break
+		}
+		s.now = at
+		if (s.events.Len() > 0) && (s.events[0].at == at) {
+			heap.Pop(&s.events).(*event).f()
+		} else {
+			s.fireTimers()
+		}
+	}
+	return s.failure
+}
+
+// nextTimer returns the virtual time of the earliest timer on any node that hasn't crashed.
+// Returns false if there are no timers.
+func (s *Simulation) nextTimer() (time.Duration, bool) {
+	var next time.Duration
+	found := false
+	for _, n := range s.nodes {
+		if n.crashed {
+			continue
+		}
+		if deadline, ok := n.manager.NextDeadline(); ok {
+			if at := deadline.Sub(epoch); !found || at < next {
+				next, found = at, true
+			}
+		}
+	}
+	if found && next < s.now {
+		next = s.now
+	}
+	return next, found
+}
+
+// fireTimers queues the turns of every timer whose deadline has been reached.
+func (s *Simulation) fireTimers() {
+	for _, n := range s.nodes {
+		if !n.crashed && (n.manager.Poll() > 0) {
+			s.record("timer %s", n.name)
+		}
+	}
+}
+
+// step runs a single turn on a randomly chosen node with pending turns.  Returns the node or nil if
+// no node has turns to run.
+func (s *Simulation) step(activate func(n *Node)) *Node {
+	count := len(s.nodes)
+	if count == 0 {
+		return nil
+	}
+
+	// Probe the nodes in order starting from a random node.
+	start := s.rand.Intn(count)
+	for i := 0; i < count; i++ {
+		n := s.nodes[(start+i)%count]
+		if n.crashed {
+			continue
+		}
+		activate(n)
+		if n.manager.RunOneTurn() {
+			return n
+		}
+	}
+	return nil
+}
+
+// deadlock returns a DeadlockError naming the unfinished nodes.
+func (s *Simulation) deadlock() *DeadlockError {
+	e := &DeadlockError{
+		Seed: s.config.Seed,
+		At:   s.now,
+	}
+	for _, n := range s.nodes {
+		if !n.finished {
+			e.Nodes = append(e.Nodes, n.name)
+		}
+	}
+	return e
+}
+
+// allFinished returns true if every node has finished.
+func (s *Simulation) allFinished() bool {
+	for _, n := range s.nodes {
+		if !n.finished {
+			return false
+		}
+	}
+	return true
+}
+
+// schedule adds an event to the timeline.
+func (s *Simulation) schedule(at time.Duration, f func()) {
+	s.seq++
+	heap.Push(&s.events, &event{
+		at:  at,
+		seq: s.seq,
+		f:   f,
+	})
+}
+
+// send transmits a packet over the simulated network.
+func (s *Simulation) send(p Packet) {
+	if s.rand.Float64() < s.config.DropRate {
+		s.record("drop %s->%s %v", p.From, p.To, p.Message)
+		return
+	}
+
+	latency := s.config.MinLatency
+	if spread := s.config.MaxLatency - s.config.MinLatency; spread > 0 {
+		latency += time.Duration(s.rand.Int63n(int64(spread) + 1))
+	}
+	s.record("send %s->%s %v (+%v)", p.From, p.To, p.Message, latency)
+	s.schedule(s.now+latency, func() {
+		s.deliver(p)
+	})
+}
+
+// deliver hands a packet that has arrived to its destination.
+func (s *Simulation) deliver(p Packet) {
+	n := s.byName[p.To]
+	switch {
+	case n == nil:
+		s.record("lost %s->%s %v (unknown node)", p.From, p.To, p.Message)
+	case n.crashed:
+		s.record("lost %s->%s %v (crashed)", p.From, p.To, p.Message)
+	case s.partition[p.From] != s.partition[p.To]:
+		s.record("lost %s->%s %v (partitioned)", p.From, p.To, p.Message)
+	default:
+		s.record("deliver %s->%s %v", p.From, p.To, p.Message)
+		n.arrive(p)
+	}
+}
+
+// record appends an entry to the history.
+func (s *Simulation) record(format string, a ...interface{}) {
+	entry := fmt.Sprintf("%v: ", s.now) + fmt.Sprintf(format, a...)
+	log.V(2).Info(entry)
+	s.history = append(s.history, entry)
+}
+
+// Node is a single simulated actor.
+type Node struct {
+	// sim is the simulation the node belongs to.
+	sim *Simulation
+
+	// name uniquely identifies the node in the simulation.
+	name string
+
+	// manager runs the node's turns.
+	manager *turns.Manager
+
+	// runner is the node's ambient runner.
+	runner async.Runner
+
+	// rand is the node's deterministic source of randomness.
+	rand *rand.Rand
+
+	// inbox is the packets that have arrived but not been received in FIFO order.
+	inbox []Packet
+
+	// receiver resolves the outstanding Receive, if any.
+	receiver async.ResolverT
+
+	// finished is true once the node's main result has been resolved or it has crashed.
+	finished bool
+
+	// crashed is true once the node has crashed.
+	crashed bool
+}
+
+// Name returns the name of the node.
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Now returns the current virtual time.
+func (n *Node) Now() time.Duration {
+	return n.sim.now
+}
+
+// Rand returns the node's deterministic source of randomness.
+func (n *Node) Rand() *rand.Rand {
+	return n.rand
+}
+
+// Send transmits a message to the named node over the simulated network.  Delivery is not
+// guaranteed: the message may be delayed, reordered with respect to other messages, or lost.
+func (n *Node) Send(to string, msg interface{}) {
+	n.sim.send(Packet{
+		From:    n.name,
+		To:      to,
+		Message: msg,
+	})
+}
+
+// Receive returns the next packet to arrive at the node.
+// REQUIRES: at most one Receive may be outstanding at a time.
+func (n *Node) Receive() PacketR {
+	assert.True(n.receiver == nil, "Only one Receive may be outstanding on node %s.", n.name)
+
+	r, s := n.runner.NewResultT()
+	if len(n.inbox) > 0 {
+		p := n.inbox[0]
+		n.inbox = n.inbox[1:]
+		s.Complete(p)
+	} else {
+		n.receiver = s
+	}
+	return PacketR{r}
+}
+
+// Sleep returns a result that is resolved once d of virtual time has elapsed.
+func (n *Node) Sleep(d time.Duration) async.R {
+	r, s := async.NewROn(n.runner)
+	n.sim.schedule(n.sim.now+d, func() {
+		if !n.crashed {
+			s.Complete()
+		}
+	})
+	return r
+}
+
+// arrive adds an arriving packet to the node's inbox or completes the outstanding Receive.
+func (n *Node) arrive(p Packet) {
+	if n.receiver != nil {
+		s := n.receiver
+		n.receiver = nil
+		s.Complete(p)
+		return
+	}
+	n.inbox = append(n.inbox, p)
+}
+
+// epoch is the time of a node's clock at the start of the simulation.
+var epoch = time.Unix(0, 0).UTC()
+
+// clock is the clock of every node in a simulation.
+type clock struct {
+	// sim is the simulation whose virtual time the clock reads.
+	sim *Simulation
+}
+
+// Now implements turns.Clock.Now().
+func (c clock) Now() time.Time {
+	return epoch.Add(c.sim.now)
+}
+
+// event is a pending occurrence on the simulation's timeline.
+type event struct {
+	// at is the virtual time at which the event occurs.
+	at time.Duration
+
+	// seq orders events that occur at the same time.
+	seq int64
+
+	// f applies the event.
+	f func()
+}
+
+// eventQueue is a min-heap of events ordered by time and then sequence.
+type eventQueue []*event
+
+// Len implements heap.Interface.
+func (q eventQueue) Len() int {
+	return len(q)
+}
+
+// Less implements heap.Interface.
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+// Swap implements heap.Interface.
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+// Push implements heap.Interface.
+func (q *eventQueue) Push(x interface{}) {
+	*q = append(*q, x.(*event))
+}
+
+// Pop implements heap.Interface.
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
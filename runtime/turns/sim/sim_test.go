@@ -0,0 +1,382 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package sim_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/sim"
+)
+
+// SimulationSuite is the test suite for Simulation.
+type SimulationSuite struct {
+	test.Suite
+}
+
+// TestSimulationSuite runs the test suite for Simulation.
+func TestSimulationSuite(t *testing.T) {
+	test.RunSuite(t, new(SimulationSuite))
+}
+
+// echo is a server node that replies to every packet with the same message until it has replied
+// count times.
+func echo(count int) func(n *sim.Node) async.R {
+	return func(n *sim.Node) async.R {
+		var loop func(i int) async.R
+		loop = func(i int) async.R {
+			if i == count {
+				return async.Done()
+			}
+			return async.When(n.Receive(), func(p sim.Packet) async.R {
+				n.Send(p.From, p.Message)
+				return loop(i + 1)
+			})
+		}
+		return loop(0)
+	}
+}
+
+// sequence is a client node that sends count numbered messages to the echo server and then
+// records the order in which the replies arrive.
+func sequence(count int, order *[]int) func(n *sim.Node) async.R {
+	return func(n *sim.Node) async.R {
+		for i := 0; i < count; i++ {
+			n.Send("server", i)
+		}
+		var loop func(i int) async.R
+		loop = func(i int) async.R {
+			if i == count {
+				return async.Done()
+			}
+			return async.When(n.Receive(), func(p sim.Packet) async.R {
+				*order = append(*order, p.Message.(int))
+				return loop(i + 1)
+			})
+		}
+		return loop(0)
+	}
+}
+
+// runSequence runs the sequence client against the echo server and returns the order in which the
+// replies arrived, the history and the outcome.
+func runSequence(config sim.Config, count int) ([]int, []string, error) {
+	var order []int
+	s := sim.New(config)
+	s.Spawn("server", echo(count))
+	s.Spawn("client", sequence(count, &order))
+	err := s.Run()
+	return order, s.History(), err
+}
+
+// PingPong verifies that messages are exchanged between nodes.
+func (t *SimulationSuite) PingPong() {
+	order, _, err := runSequence(sim.Config{
+		Seed:       1,
+		MinLatency: time.Millisecond,
+		MaxLatency: time.Millisecond,
+	}, 10)
+	if err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if len(order) != 10 {
+		t.Errorf("Expected all replies.  Got: %v, Want: 10 replies", order)
+	}
+}
+
+// Deterministic verifies that the same seed produces the same execution.
+func (t *SimulationSuite) Deterministic() {
+	config := sim.Config{
+		Seed:       42,
+		MinLatency: time.Millisecond,
+		MaxLatency: 100 * time.Millisecond,
+	}
+	order1, history1, _ := runSequence(config, 20)
+	order2, history2, _ := runSequence(config, 20)
+	if !reflect.DeepEqual(order1, order2) {
+		t.Errorf("Expected same order.  Got: %v, Want: %v", order2, order1)
+	}
+	if !reflect.DeepEqual(history1, history2) {
+		t.Errorf("Expected same history.  Got: %v, Want: %v", history2, history1)
+	}
+
+	// Latency is random so replies are reordered.
+	reordered := false
+	for i, v := range order1 {
+		reordered = reordered || (v != i)
+	}
+	if !reordered {
+		t.Errorf("Expected reordering.  Got: %v, Want: reordered", order1)
+	}
+}
+
+// FailingSeedReproduces verifies that a seed on which a protocol fails fails identically when it
+// is run again.
+func (t *SimulationSuite) FailingSeedReproduces() {
+	// The client incorrectly assumes that the network preserves order.
+	run := func(seed int64) error {
+		s := sim.New(sim.Config{
+			Seed:       seed,
+			MinLatency: time.Millisecond,
+			MaxLatency: 10 * time.Millisecond,
+		})
+		s.Spawn("server", echo(3))
+		s.Spawn("client", func(n *sim.Node) async.R {
+			var order []int
+			return async.When(sequence(3, &order)(n), func() error {
+				for i, v := range order {
+					if v != i {
+						return fmt.Errorf("out of order: %v", order)
+					}
+				}
+				return nil
+			})
+		})
+		return s.Run()
+	}
+
+	for seed := int64(0); seed < 100; seed++ {
+		err := run(seed)
+		if err == nil {
+			continue
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("seed %d", seed)) {
+			t.Errorf("Expected the seed in the error.  Got: %v, Want: seed %d", err, seed)
+		}
+		if again := run(seed); again == nil || again.Error() != err.Error() {
+			t.Errorf("Expected same failure.  Got: %v, Want: %v", again, err)
+		}
+		return
+	}
+	t.Errorf("Expected some seed to fail.  Got: none, Want: some")
+}
+
+// Drop verifies that lossy networks drop messages.
+func (t *SimulationSuite) Drop() {
+	s := sim.New(sim.Config{
+		Seed:     7,
+		DropRate: 1,
+	})
+	received := false
+	s.Spawn("server", func(n *sim.Node) async.R {
+		return async.When(n.Receive(), func() {
+			received = true
+		})
+	})
+	s.Spawn("client", func(n *sim.Node) async.R {
+		n.Send("server", "lost")
+		return async.Done()
+	})
+
+	// The server waits forever for the dropped message.
+	err := s.Run()
+	if d, ok := err.(*sim.DeadlockError); !ok || !reflect.DeepEqual(d.Nodes, []string{"server"}) {
+		t.Errorf("Expected the server to deadlock.  Got: %v, Want: server blocked", err)
+	}
+	if received {
+		t.Errorf("Expected message to be dropped.  Got: %v, Want: false", received)
+	}
+}
+
+// PartitionIDs verifies that partition groups created at different times stay separate.
+func (t *SimulationSuite) PartitionIDs() {
+	s := sim.New(sim.Config{
+		Seed:       3,
+		MinLatency: time.Millisecond,
+		MaxLatency: time.Millisecond,
+	})
+	received := false
+	s.Spawn("a", func(n *sim.Node) async.R {
+		return async.When(n.Sleep(time.Second), func() {
+			n.Send("b", "hello")
+		})
+	})
+	s.Spawn("b", func(n *sim.Node) async.R {
+		async.When(n.Receive(), func() {
+			received = true
+		})
+		return async.Done()
+	})
+	s.Partition("a", "b")
+	s.Partition("a")
+	s.Partition("b")
+
+	if err := s.Run(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if received {
+		t.Errorf("Expected a and b to stay partitioned.  Got: %v, Want: false", received)
+	}
+}
+
+// PartitionAndHeal verifies that partitioned nodes cannot communicate until the partition heals.
+func (t *SimulationSuite) PartitionAndHeal() {
+	s := sim.New(sim.Config{
+		Seed:       3,
+		MinLatency: time.Millisecond,
+		MaxLatency: time.Millisecond,
+	})
+	var got []string
+	s.Spawn("server", func(n *sim.Node) async.R {
+		return async.When(n.Receive(), func(p sim.Packet) {
+			got = append(got, p.Message.(string))
+		})
+	})
+	s.Spawn("client", func(n *sim.Node) async.R {
+		n.Send("server", "during")
+		return async.When(n.Sleep(time.Second), func() {
+			n.Send("server", "after")
+		})
+	})
+	s.Partition("client")
+	s.At(500*time.Millisecond, s.Heal)
+
+	if err := s.Run(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if !reflect.DeepEqual(got, []string{"after"}) {
+		t.Errorf("Expected only the message after healing.  Got: %v, Want: [after]", got)
+	}
+}
+
+// Crash verifies that crashed nodes stop running and lose their messages.
+func (t *SimulationSuite) Crash() {
+	s := sim.New(sim.Config{
+		Seed:       5,
+		MinLatency: time.Millisecond,
+		MaxLatency: time.Millisecond,
+	})
+	received := false
+	s.Spawn("server", func(n *sim.Node) async.R {
+		return async.When(n.Receive(), func() {
+			received = true
+		})
+	})
+	s.Spawn("client", func(n *sim.Node) async.R {
+		n.Send("server", "lost")
+		return async.Done()
+	})
+	s.Crash("server")
+
+	if err := s.Run(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if received {
+		t.Errorf("Expected crashed node not to receive.  Got: %v, Want: false", received)
+	}
+}
+
+// VirtualTime verifies that sleeping takes virtual time rather than real time.
+func (t *SimulationSuite) VirtualTime() {
+	s := sim.New(sim.Config{Seed: 1})
+	var woke time.Duration
+	s.Spawn("sleeper", func(n *sim.Node) async.R {
+		return async.When(n.Sleep(time.Hour), func() {
+			woke = n.Now()
+		})
+	})
+
+	start := time.Now()
+	if err := s.Run(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if woke != time.Hour {
+		t.Errorf("Expected virtual time to advance.  Got: %v, Want: %v", woke, time.Hour)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Expected no real time to pass.  Got: %v, Want: < 1m", elapsed)
+	}
+}
+
+// After verifies that timers use virtual time and that a seed replays the same trace.
+func (t *SimulationSuite) After() {
+	run := func() ([]int, []string, time.Duration) {
+		var order []int
+		var finished time.Duration
+		s := sim.New(sim.Config{
+			Seed:       11,
+			MinLatency: time.Millisecond,
+			MaxLatency: time.Millisecond,
+		})
+		s.Spawn("server", echo(5))
+		s.Spawn("client", func(n *sim.Node) async.R {
+			// Send each message after a random delay.
+			for i := 0; i < 5; i++ {
+				i := i
+				async.When(async.After(time.Duration(n.Rand().Intn(1000))*time.Second), func() {
+					n.Send("server", i)
+				})
+			}
+			var loop func(i int) async.R
+			loop = func(i int) async.R {
+				if i == 5 {
+					finished = n.Now()
+					return async.Done()
+				}
+				return async.When(n.Receive(), func(p sim.Packet) async.R {
+					order = append(order, p.Message.(int))
+					return loop(i + 1)
+				})
+			}
+			return loop(0)
+		})
+		if err := s.Run(); err != nil {
+			t.Errorf("Expected success.  Got: %v, Want: nil", err)
+		}
+		return order, s.History(), finished
+	}
+
+	start := time.Now()
+	order1, history1, finished := run()
+	order2, history2, _ := run()
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Expected no real time to pass.  Got: %v, Want: < 1m", elapsed)
+	}
+	if finished < time.Second {
+		t.Errorf("Expected virtual time to advance.  Got: %v, Want: >= 1s", finished)
+	}
+	if len(order1) != 5 || !reflect.DeepEqual(order1, order2) {
+		t.Errorf("Expected same order.  Got: %v, Want: %v", order2, order1)
+	}
+	if !reflect.DeepEqual(history1, history2) {
+		t.Errorf("Expected same history.  Got: %v, Want: %v", history2, history1)
+	}
+}
+
+// MaxTime verifies that a simulation stops once its virtual time limit is reached.
+func (t *SimulationSuite) MaxTime() {
+	s := sim.New(sim.Config{
+		Seed:    1,
+		MaxTime: time.Minute,
+	})
+	woke := false
+	s.Spawn("sleeper", func(n *sim.Node) async.R {
+		return async.When(n.Sleep(time.Hour), func() {
+			woke = true
+		})
+	})
+	if err := s.Run(); err != nil {
+		t.Errorf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if woke {
+		t.Errorf("Expected the simulation to stop first.  Got: %v, Want: false", woke)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package test_test
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// SeededSuite is the test suite for RunSuiteWithSeeds.
+type SeededSuite struct {
+	test.Suite
+
+	// orders are the distinct orders in which Interleave's turns ran.
+	orders map[string]bool
+}
+
+// TestSeededSuite runs the test suite for RunSuiteWithSeeds.
+func TestSeededSuite(t *testing.T) {
+	test.RunSuiteWithSeeds(t, &SeededSuite{orders: make(map[string]bool)}, 20)
+}
+
+// Interleave runs independent chains of turns and records the order in which they ran.
+func (t *SeededSuite) Interleave() async.R {
+	var order []string
+	chain := func(name string) async.R {
+		return async.When(async.New(func() async.R {
+			order = append(order, name+"1")
+			return async.Done()
+		}), func() {
+			order = append(order, name+"2")
+		})
+	}
+	a, b := chain("a"), chain("b")
+	return async.When(a, func() async.R {
+		return async.When(b, func() error {
+			// Turns from the same chain always run in order.
+			for i, step := range order {
+				for _, later := range order[i+1:] {
+					if later[0] == step[0] && later[1] < step[1] {
+						return fmt.Errorf("Expected chain order.  Got: %v, Want: ordered", order)
+					}
+				}
+			}
+			t.orders[fmt.Sprint(order)] = true
+			return nil
+		})
+	})
+}
+
+// VerifyInterleavings verifies that different seeds produced different interleavings.  It runs
+// after Interleave because test methods run in alphabetical order.
+func (t *SeededSuite) VerifyInterleavings() {
+	// A single seed may have been selected on the command line.
+	if f := flag.Lookup("turns.seed"); f != nil && f.Value.String() != "0" {
+		return
+	}
+	if len(t.orders) < 2 {
+		t.Errorf("Expected several interleavings.  Got: %v, Want: more than 1", t.orders)
+	}
+}
@@ -19,26 +19,103 @@ package test
 // of turn-based tests as a suite.
 
 import (
+	"flag"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/prolang/drydock/runtime/base/assert"
 	"github.com/prolang/drydock/runtime/base/test"
 	"github.com/prolang/drydock/runtime/turns/actor"
 	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
 )
 
 // Suite is the base type for turn-based test package.  All test suites using the test package
 // should embed this value.
 type Suite struct {
 	test.Suite
+
+	// clock is the virtual clock of the running test (if any).
+	clock *turns.FakeClock
+}
+
+// Clock returns the virtual clock of the running asynchronous test when the suite is run with
+// RunSuiteWithVirtualTime, otherwise nil.
+func (t *Suite) Clock() *turns.FakeClock {
+	return t.clock
+}
+
+// setClock sets the virtual clock of the running test.
+func (t *Suite) setClock(clock *turns.FakeClock) {
+	t.clock = clock
 }
 
+// clockSetter is implemented by every suite that embeds Suite.
+type clockSetter interface {
+	setClock(clock *turns.FakeClock)
+}
+
+// VirtualEpoch is the time at which virtual clocks start.
+var VirtualEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// seed (if non-zero) restricts RunSuiteWithSeeds to a single seed so a failure can be reproduced.
+var seed = flag.Int64("turns.seed", 0, "run seeded turn tests with only this seed")
+
 // RunSuite runs all test methods within a test suite and reports their outcome to stdout.
 func RunSuite(t *testing.T, suite interface{}) {
 	test.RunSuiteCustom(t, suite, filterTurnTests, dispatchTurnTests)
 }
 
+// RunSuiteWithVirtualTime is like RunSuite but runs each asynchronous test method with a new
+// virtual clock (see turns.FakeClock) starting at VirtualEpoch.  If auto is true then time jumps to
+// the next timer's deadline whenever the test's actor is idle, otherwise time only moves when the
+// test advances it (through Suite.Clock).  Either way no wall clock time is spent waiting.
+func RunSuiteWithVirtualTime(t *testing.T, suite interface{}, auto bool) {
+	test.RunSuiteCustom(t, suite, filterTurnTests, func(s *test.Suite, v reflect.Value,
+		f reflect.Value) {
+		clock := turns.NewFakeClock(VirtualEpoch, auto)
+		setter, ok := v.Interface().(clockSetter)
+		assert.True(ok, "Suites run with virtual time MUST embed turns/test.Suite.")
+		setter.setClock(clock)
+		defer setter.setClock(nil)
+
+		dispatchTurnTest(s, v, f, func(m *turns.Manager) {
+			m.SetClock(clock)
+		})
+	})
+}
+
+// RunSuiteWithSeeds is like RunSuite but runs each asynchronous test method seeds times, each time
+// with the actor's turns scheduled randomly from a different seed (see turns.Manager.Randomize).
+// A failing test reports the seed on which it failed.  The failure can be reproduced by running
+// the test with -turns.seed set to that seed.
+func RunSuiteWithSeeds(t *testing.T, suite interface{}, seeds int) {
+	test.RunSuiteCustom(t, suite, filterTurnTests, func(s *test.Suite, v reflect.Value,
+		f reflect.Value) {
+		// Synchronous tests don't run turns so are only run once.
+		if f.Type().NumOut() == 0 {
+			dispatchTurnTest(s, v, f, nil)
+			return
+		}
+
+		first, last := int64(1), int64(seeds)
+		if *seed != 0 {
+			first, last = *seed, *seed
+		}
+		for i := first; i <= last; i++ {
+			i := i
+			dispatchTurnTest(s, v, f, func(m *turns.Manager) {
+				m.Randomize(i)
+			})
+			if s.Failed() {
+				s.Errorf("Failed with seed %d.  Rerun with -turns.seed=%d.", i, i)
+				return
+			}
+		}
+	})
+}
+
 // filterTurnTests filters tests to only those that are either synchronous or asynchronous
 // turn-based test methods.
 func filterTurnTests(m reflect.Method) bool {
@@ -66,6 +143,15 @@ var (
 
 // dispatchTurnTests dispatches both synchronous and turn-based asynchronous tests.
 func dispatchTurnTests(s *test.Suite, v reflect.Value, f reflect.Value) {
+	dispatchTurnTest(s, v, f, nil)
+}
+
+// dispatchTurnTest dispatches a synchronous or turn-based asynchronous test.  configure (if not
+// nil) is called with the turn manager of the actor running an asynchronous test.  The manager
+// records call sites so that a test that deadlocks reports where its unresolved results were
+// created.
+func dispatchTurnTest(s *test.Suite, v reflect.Value, f reflect.Value,
+	configure func(m *turns.Manager)) {
 	assert.True(f.Kind() == reflect.Func, "Test function MUST be a function")
 
 	// If it is a synchronous test method then just run it.
@@ -84,7 +170,12 @@ func dispatchTurnTests(s *test.Suite, v reflect.Value, f reflect.Value) {
 		return f.Call(inputs)
 	})
 
-	err := actor.RunActor(fn.Interface().(async.Func))
+	err := actor.RunActorWith(func(m *turns.Manager) {
+		m.RecordCallSites()
+		if configure != nil {
+			configure(m)
+		}
+	}, fn.Interface().(async.Func))
 	if err != nil {
 		s.Errorf("Expected test case retval to succeed.  Got: %q, Want: nil", err)
 	}
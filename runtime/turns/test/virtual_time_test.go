@@ -0,0 +1,111 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package test_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/test"
+)
+
+// AutoTimeSuite is the test suite for automatically advancing virtual time.
+type AutoTimeSuite struct {
+	test.Suite
+}
+
+// TestAutoTimeSuite runs the test suite for automatically advancing virtual time.
+func TestAutoTimeSuite(t *testing.T) {
+	test.RunSuiteWithVirtualTime(t, new(AutoTimeSuite), true)
+}
+
+// LongSleep verifies that sleeping takes virtual time rather than wall clock time.
+func (t *AutoTimeSuite) LongSleep() async.R {
+	start, wall := async.Now(), time.Now()
+	if !start.Equal(test.VirtualEpoch) {
+		return async.NewErrorf("Expected virtual epoch.  Got: %v, Want: %v", start, test.VirtualEpoch)
+	}
+	return async.When(async.After(time.Hour), func() error {
+		if elapsed := async.Now().Sub(start); elapsed != time.Hour {
+			return fmt.Errorf("Expected virtual time to pass.  Got: %v, Want: %v", elapsed, time.Hour)
+		}
+		if elapsed := time.Since(wall); elapsed > time.Minute {
+			return fmt.Errorf("Expected no wall clock time to pass.  Got: %v, Want: < 1m", elapsed)
+		}
+		return nil
+	})
+}
+
+// TimerOrder verifies that timers fire in deadline order.
+func (t *AutoTimeSuite) TimerOrder() async.R {
+	var order []int
+	record := func(i int) func() {
+		return func() { order = append(order, i) }
+	}
+	r3 := async.When(async.After(3*time.Second), record(3))
+	r1 := async.When(async.After(time.Second), record(1))
+	r2 := async.When(async.After(2*time.Second), record(2))
+	return async.When(r3, func() async.R {
+		return async.When(r1, func() async.R {
+			return async.When(r2, func() error {
+				if fmt.Sprint(order) != "[1 2 3]" {
+					return fmt.Errorf("Expected deadline order.  Got: %v, Want: [1 2 3]", order)
+				}
+				return nil
+			})
+		})
+	})
+}
+
+// ManualTimeSuite is the test suite for explicitly advanced virtual time.
+type ManualTimeSuite struct {
+	test.Suite
+}
+
+// TestManualTimeSuite runs the test suite for explicitly advanced virtual time.
+func TestManualTimeSuite(t *testing.T) {
+	test.RunSuiteWithVirtualTime(t, new(ManualTimeSuite), false)
+}
+
+// Advance verifies that timers only fire once the clock has been advanced past their deadline.
+func (t *ManualTimeSuite) Advance() async.R {
+	fired := false
+	timer := async.When(async.After(time.Minute), func() {
+		fired = true
+	})
+
+	// Advance the clock in two steps: first from a turn on the actor itself (which must not fire the
+	// timer early) and then from a separate goroutine, as a test harness would.
+	clock := t.Clock()
+	step := async.When(async.New(func() async.R {
+		clock.Advance(30 * time.Second)
+		return async.Done()
+	}), func() async.R {
+		if fired {
+			return async.NewErrorf("Expected timer NOT to fire early.  Got: %v, Want: false", fired)
+		}
+		go clock.Advance(30 * time.Second)
+		return timer
+	})
+	return async.When(step, func() error {
+		if !fired {
+			return fmt.Errorf("Expected timer to fire.  Got: %v, Want: true", fired)
+		}
+		return nil
+	})
+}
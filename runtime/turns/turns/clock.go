@@ -0,0 +1,117 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/base"
+)
+
+// Clock is the source of time for a Manager's timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// realClock is a Clock that returns the wall clock time.
+type realClock struct{}
+
+// RealClock returns a Clock that returns the wall clock time.  It is the default for a Manager.
+func RealClock() Clock {
+	return realClock{}
+}
+
+// Now implements Clock.Now().
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock whose time only moves when it is advanced.  A FakeClock is either advanced
+// explicitly (by calling Advance) or automatically: a Manager whose automatic FakeClock has no work
+// to do jumps the clock straight to the deadline of its next timer.
+type FakeClock struct {
+	// auto is true if managers advance the clock automatically when they are idle.
+	auto bool
+
+	// lock protects now and watchers.
+	lock sync.Mutex
+
+	// now is the current time.
+	now time.Time
+
+	// watchers are signalled whenever the clock is advanced.
+	watchers []*base.Event
+}
+
+// NewFakeClock creates a new FakeClock whose time is start.  If auto is true then managers using
+// the clock advance it automatically whenever they are idle.
+func NewFakeClock(start time.Time, auto bool) *FakeClock {
+	return &FakeClock{
+		auto: auto,
+		now:  start,
+	}
+}
+
+// Now implements Clock.Now().
+// THREADING: This method is multi-thread safe.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and wakes any managers using the clock.
+// THREADING: This method is multi-thread safe.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	watchers := append([]*base.Event(nil), c.watchers...)
+	c.lock.Unlock()
+
+	for _, e := range watchers {
+		e.Signal()
+	}
+}
+
+// advanceTo moves the clock forward to t (if t is in the future).
+func (c *FakeClock) advanceTo(t time.Time) {
+	c.lock.Lock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	c.lock.Unlock()
+}
+
+// watch registers e to be signalled whenever the clock is advanced.
+func (c *FakeClock) watch(e *base.Event) {
+	c.lock.Lock()
+	c.watchers = append(c.watchers, e)
+	c.lock.Unlock()
+}
+
+// unwatch removes e from the events signalled when the clock is advanced.
+func (c *FakeClock) unwatch(e *base.Event) {
+	c.lock.Lock()
+	for i, w := range c.watchers {
+		if w == e {
+			c.watchers = append(c.watchers[:i], c.watchers[i+1:]...)
+			break
+		}
+	}
+	c.lock.Unlock()
+}
@@ -0,0 +1,192 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the diagnosis of deadlocks.  A manager is deadlocked when main is unresolved
+// but there are no turns to run, no timers and no I/O sources from which turns could arrive.  No
+// further progress is possible, so every continuation still waiting on an unresolved result will
+// never run.  Reporting those results (and where they were created) usually points directly at the
+// computation that was never completed.
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+)
+
+// callSiteDepth is the number of stack frames recorded when a result is created.  It is enough to
+// see through the async and turns packages to the application code that created the result.
+const callSiteDepth = 8
+
+// callSites is the stack of program counters at which a result was created.
+type callSites [callSiteDepth]uintptr
+
+// runtimePackages are the package paths whose frames are skipped when reporting a call site.
+var runtimePackages = []string{
+	reflect.TypeOf(Manager{}).PkgPath() + ".",
+	reflect.TypeOf(async.R{}).PkgPath() + ".",
+	"reflect.",
+	"runtime.",
+}
+
+// CallSite is a location in the program.
+type CallSite struct {
+	// Function is the fully qualified name of the function.
+	Function string
+
+	// File is the source file.
+	File string
+
+	// Line is the line number in File.
+	Line int
+}
+
+// String implements fmt.Stringer.
+func (c CallSite) String() string {
+	if c.Function == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (%s:%d)", c.Function, c.File, c.Line)
+}
+
+// RecordCallSites causes the manager to record where each result is created so that the results
+// reported in a DeadlockError can be located.  Recording is off by default because walking the stack
+// roughly doubles the cost of creating a result.  Without it the results and their continuations are
+// still reported but their call sites are unknown.
+// RecordCallSites MUST be called before the manager starts running turns.
+func (m *Manager) RecordCallSites() {
+	m.callSites = true
+}
+
+// callSite returns the first frame in pcs that is outside of the turn runtime, or the first frame
+// if there is none.  Returns the zero CallSite if pcs is nil.
+func (pcs *callSites) callSite() CallSite {
+	if pcs == nil {
+		return CallSite{}
+	}
+	n := 0
+	for n < len(pcs) && pcs[n] != 0 {
+		n++
+	}
+	var first CallSite
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		site := CallSite{Function: frame.Function, File: frame.File, Line: frame.Line}
+		if first.Function == "" {
+			first = site
+		}
+		internal := false
+		for _, prefix := range runtimePackages {
+			internal = internal || strings.HasPrefix(frame.Function, prefix)
+		}
+		if !internal {
+			return site
+		}
+		if !more {
+			return first
+		}
+	}
+}
+
+// WaitingResult is an unresolved result that has continuations waiting for it.
+type WaitingResult struct {
+	// Site is where the result was created (or the zero CallSite if the manager does not record
+	// call sites).
+	Site CallSite
+
+	// Continuations are the names of the turns waiting for the result, in the order they will run.
+	Continuations []string
+}
+
+// DeadlockError is returned by RunUntil if main is unresolved and no further progress can be made
+// because there are no turns to run, no timers and no I/O sources.
+type DeadlockError struct {
+	// Waiting are the unresolved results that have continuations, oldest first.
+	Waiting []WaitingResult
+}
+
+// Error implements error.Error().
+func (e *DeadlockError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "deadlock: main is unresolved and there are no turns, timers or I/O sources; "+
+		"%d unresolved results have continuations", len(e.Waiting))
+	for _, w := range e.Waiting {
+		fmt.Fprintf(&buf, "\n  result created at %v: %s", w.Site, strings.Join(w.Continuations, ", "))
+	}
+	return buf.String()
+}
+
+// deadlock describes the unresolved results that have continuations.
+func (m *Manager) deadlock() *DeadlockError {
+	err := &DeadlockError{}
+	for s := m.waiting; s != nil; s = s.waitNext {
+		err.Waiting = append(err.Waiting, WaitingResult{
+			Site:          s.sites.callSite(),
+			Continuations: s.continuations(),
+		})
+	}
+
+	// Results are added to the front of the list, so reverse it to put the oldest first.
+	for i, j := 0, len(err.Waiting)-1; i < j; i, j = i+1, j-1 {
+		err.Waiting[i], err.Waiting[j] = err.Waiting[j], err.Waiting[i]
+	}
+	return err
+}
+
+// continuations returns the names of the turns waiting for s, in the order they will run.
+func (s *turnResolver) continuations() []string {
+	if s.turns.IsEmpty() {
+		return nil
+	}
+	var names []string
+	t := s.turns.Peek()
+	for {
+		names = append(names, t.Name())
+		if t == s.turns {
+			return names
+		}
+		t = t.next
+	}
+}
+
+// addWaiting adds s to the manager's list of unresolved results that have continuations.
+func (s *turnResolver) addWaiting() {
+	m := s.manager
+	s.waitNext = m.waiting
+	if m.waiting != nil {
+		m.waiting.waitPrev = s
+	}
+	m.waiting = s
+}
+
+// removeWaiting removes s from the manager's list of unresolved results that have continuations.
+func (s *turnResolver) removeWaiting() {
+	m := s.manager
+	if s.waitPrev != nil {
+		s.waitPrev.waitNext = s.waitNext
+	} else {
+		m.waiting = s.waitNext
+	}
+	if s.waitNext != nil {
+		s.waitNext.waitPrev = s.waitPrev
+	}
+	s.waitPrev, s.waitNext = nil, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// DeadlockSuite is the test suite for deadlock diagnosis.
+type DeadlockSuite struct {
+	test.Suite
+}
+
+// TestDeadlockSuite runs the test suite for deadlock diagnosis.
+func TestDeadlockSuite(t *testing.T) {
+	test.RunSuite(t, new(DeadlockSuite))
+}
+
+// ReportsWaitingResults verifies that RunUntil returns the results that will never be resolved.
+func (t *DeadlockSuite) ReportsWaitingResults() {
+	err := runManager(func(m *turns.Manager) {
+		m.RecordCallSites()
+	}, func() async.R {
+		orphan, _ := async.NewR()
+		first := async.When(orphan, func() {})
+		second := async.When(orphan, func() {})
+		return async.When(first, func() async.R {
+			return second
+		})
+	})
+
+	deadlock, ok := err.(*turns.DeadlockError)
+	if !ok {
+		t.Fatalf("Expected deadlock.  Got: %v, Want: *DeadlockError", err)
+	}
+	var orphan *turns.WaitingResult
+	for i, w := range deadlock.Waiting {
+		if len(w.Continuations) == 2 {
+			orphan = &deadlock.Waiting[i]
+		}
+	}
+	if orphan == nil {
+		t.Fatalf("Expected the orphan with two continuations.  Got: %v, Want: orphan", err)
+	}
+	if !strings.HasSuffix(orphan.Site.File, "deadlock_test.go") {
+		t.Errorf("Expected the creation site.  Got: %v, Want: deadlock_test.go", orphan.Site)
+	}
+	for _, name := range orphan.Continuations {
+		if !strings.HasPrefix(name, "When") {
+			t.Errorf("Expected continuation names.  Got: %v, Want: When...", orphan.Continuations)
+		}
+	}
+	if !strings.Contains(err.Error(), "deadlock_test.go") {
+		t.Errorf("Expected the site in the message.  Got: %v, Want: deadlock_test.go", err)
+	}
+}
+
+// WithoutCallSites verifies that the results are reported (without their sites) even if the
+// manager does not record call sites.
+func (t *DeadlockSuite) WithoutCallSites() {
+	err := runManager(func(m *turns.Manager) {}, func() async.R {
+		orphan, _ := async.NewR()
+		return async.When(orphan, func() {})
+	})
+
+	deadlock, ok := err.(*turns.DeadlockError)
+	if !ok {
+		t.Fatalf("Expected deadlock.  Got: %v, Want: *DeadlockError", err)
+	}
+	found := false
+	for _, w := range deadlock.Waiting {
+		if (len(w.Continuations) == 1) && strings.HasPrefix(w.Continuations[0], "When") {
+			found = true
+			if w.Site != (turns.CallSite{}) {
+				t.Errorf("Expected no site.  Got: %v, Want: unknown", w.Site)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the orphan's continuation.  Got: %v, Want: When", err)
+	}
+}
+
+// FakeClock verifies that a manager whose explicitly advanced clock has no timers waiting for it
+// deadlocks rather than waiting for the clock forever.
+func (t *DeadlockSuite) FakeClock() {
+	clock := turns.NewFakeClock(time.Unix(0, 0), false)
+	fired := false
+	err := runManager(func(m *turns.Manager) {
+		m.SetClock(clock)
+	}, func() async.R {
+		timer := async.After(time.Second)
+		go clock.Advance(time.Second)
+		return async.When(timer, func() async.R {
+			fired = true
+			orphan, _ := async.NewR()
+			return orphan
+		})
+	})
+	if !fired {
+		t.Errorf("Expected the timer to fire.  Got: %v, Want: true", fired)
+	}
+	if _, ok := err.(*turns.DeadlockError); !ok {
+		t.Errorf("Expected deadlock.  Got: %v, Want: *DeadlockError", err)
+	}
+}
+
+// Recoverable verifies that a deadlocked manager can still be used afterwards.
+func (t *DeadlockSuite) Recoverable() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	r, s := async.NewR()
+	if _, ok := m.RunUntil(r).(*turns.DeadlockError); !ok {
+		t.Fatalf("Expected deadlock.  Got: nil, Want: *DeadlockError")
+	}
+	s.Complete()
+	if err := m.RunUntil(r); err != nil {
+		t.Errorf("Expected success after resolving.  Got: %v, Want: nil", err)
+	}
+}
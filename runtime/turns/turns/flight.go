@@ -0,0 +1,308 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the flight recorder.  A flight recorder keeps a bounded ring of a manager's
+// most recent events (turns queued and run, results resolved and forwarded, and I/O completions
+// arriving) in memory.  The ring is dumped when something goes wrong so that the post-mortem shows
+// what the manager was doing leading up to the failure rather than just a one-line message:
+//
+//   - an assertion fails (see assert.AddFailureHook),
+//   - a panic escapes a turn run by RunUntil or RunTurns,
+//   - the process receives SIGQUIT (on platforms that have it), before the default goroutine dump.
+//
+// Dumps are written to stderr unless redirected with SetFlightRecorderOutput.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+
+	log "github.com/golang/glog"
+)
+
+// DefaultFlightRecorderSize is the number of events kept by a flight recorder if no size is given.
+const DefaultFlightRecorderSize = 1024
+
+// flightKind is the kind of an event recorded by a flight recorder.
+type flightKind int
+
+const (
+	flightQueue flightKind = iota
+	flightRun
+	flightResolve
+	flightForward
+	flightIO
+)
+
+// flightKindNames are the names of each flightKind in dumps.
+var flightKindNames = [...]string{
+	flightQueue:   "queue",
+	flightRun:     "run",
+	flightResolve: "resolve",
+	flightForward: "forward",
+	flightIO:      "io",
+}
+
+// flightEvent is a single event recorded by a flight recorder.
+type flightEvent struct {
+	at   time.Time
+	kind flightKind
+
+	// label and id identify the turn.  For resolve and forward events they identify the turn that was
+	// running when the result was resolved or forwarded.
+	label string
+	id    UniqueID
+
+	// detail is additional information (e.g. the source of an I/O completion or the error with which
+	// a result failed).
+	detail string
+}
+
+// FlightRecorder keeps a bounded ring of a manager's most recent events.
+type FlightRecorder struct {
+	// name identifies the manager in dumps.
+	name string
+
+	// lock protects the fields below which are written on the manager's thread but may be dumped
+	// from any thread.
+	lock sync.Mutex
+
+	// events is the ring of events.
+	events []flightEvent
+
+	// next is the index in events at which the next event is recorded.
+	next int
+
+	// full is true once the ring has wrapped.
+	full bool
+
+	// current is the turn currently running on the manager (or nil).  Only accessed on the manager's
+	// thread.
+	current *Turn
+}
+
+// EnableFlightRecorder starts recording the manager's most recent size events (or
+// DefaultFlightRecorderSize if size is not positive).  name identifies the manager in dumps.  The
+// recorder is dumped (along with all other open recorders) when an assertion fails or the process
+// receives SIGQUIT, and when a panic escapes one of the manager's turns.  The recorder is closed
+// when the manager is closed (or by calling Close).
+// EnableFlightRecorder MUST be called before the manager starts running turns.
+func (m *Manager) EnableFlightRecorder(name string, size int) *FlightRecorder {
+	if size <= 0 {
+		size = DefaultFlightRecorderSize
+	}
+	f := &FlightRecorder{
+		name:   name,
+		events: make([]flightEvent, size),
+	}
+	m.flight = f
+	m.OnClose(f.Close)
+	registerFlightRecorder(f)
+	return f
+}
+
+// FlightRecorder returns the manager's flight recorder, or nil if there is none.
+func (m *Manager) FlightRecorder() *FlightRecorder {
+	return m.flight
+}
+
+// Close stops the recorder from being dumped on assertion failures and SIGQUIT.
+// THREADING: This method is multi-thread safe.
+func (f *FlightRecorder) Close() {
+	flightLock.Lock()
+	delete(flightRecorders, f)
+	flightLock.Unlock()
+}
+
+// Events returns a description of each recorded event, oldest first.
+// THREADING: This method is multi-thread safe.
+func (f *FlightRecorder) Events() []string {
+	f.lock.Lock()
+	var events []flightEvent
+	if f.full {
+		events = append(events, f.events[f.next:]...)
+	}
+	events = append(events, f.events[:f.next]...)
+	f.lock.Unlock()
+
+	result := make([]string, len(events))
+	for i, e := range events {
+		name := e.label
+		if !e.id.IsZero() {
+			name += e.id.String()
+		}
+		if e.detail != "" {
+			name += " " + e.detail
+		}
+		result[i] = fmt.Sprintf("%s %-7s %s", e.at.Format("15:04:05.000000"), flightKindNames[e.kind],
+			name)
+	}
+	return result
+}
+
+// Dump writes the recorded events, oldest first, to w.  reason describes why the dump was taken.
+// THREADING: This method is multi-thread safe.
+func (f *FlightRecorder) Dump(w io.Writer, reason string) error {
+	events := f.Events()
+	if _, err := fmt.Fprintf(w, "flight recorder %q (%s): last %d events\n", f.name, reason,
+		len(events)); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if _, err := fmt.Fprintf(w, "  %s\n", e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record adds an event to the ring, overwriting the oldest event if the ring is full.
+func (f *FlightRecorder) record(kind flightKind, label string, id UniqueID, detail string) {
+	f.lock.Lock()
+	f.events[f.next] = flightEvent{
+		at:     time.Now(),
+		kind:   kind,
+		label:  label,
+		id:     id,
+		detail: detail,
+	}
+	if f.next++; f.next == len(f.events) {
+		f.next, f.full = 0, true
+	}
+	f.lock.Unlock()
+}
+
+// begin records that turn t has started running.  Returns the turn that was running before it (if
+// any) which MUST be passed to end when t finishes.
+func (f *FlightRecorder) begin(t *Turn) *Turn {
+	f.record(flightRun, t.label, t.id, "")
+	outer := f.current
+	f.current = t
+	return outer
+}
+
+// end records that the running turn has finished and outer is running again.
+func (f *FlightRecorder) end(outer *Turn) {
+	f.current = outer
+}
+
+// recordResult records an event for a result resolved or forwarded by the running turn.
+func (f *FlightRecorder) recordResult(kind flightKind, detail string) {
+	var label string
+	var id UniqueID
+	if f.current != nil {
+		label, id = f.current.label, f.current.id
+	}
+	f.record(kind, label, id, detail)
+}
+
+// recordIngest records the arrival of each I/O completion in list from source.
+func (f *FlightRecorder) recordIngest(source string, list *Turn) {
+	if list.IsEmpty() {
+		return
+	}
+	t := list.Peek()
+	for {
+		f.record(flightIO, t.label, t.id, source)
+		if t == list {
+			break
+		}
+		t = t.next
+	}
+}
+
+// dumpOnPanic dumps the recorder if the calling goroutine is panicking and then continues
+// panicking.
+// REQUIRES: MUST be called directly by a deferred call.
+func (f *FlightRecorder) dumpOnPanic() {
+	if r := recover(); r != nil {
+		dumpFlightRecorders([]*FlightRecorder{f}, fmt.Sprintf("panic: %v", r))
+		panic(r)
+	}
+}
+
+// flightRecorders are the open flight recorders.
+var flightRecorders = make(map[*FlightRecorder]bool)
+
+// flightOutput is the path of the file to which dumps are appended, or "" for stderr.
+var flightOutput string
+
+// flightLock protects flightRecorders and flightOutput.
+var flightLock sync.Mutex
+
+// flightOnce installs the assertion and signal hooks the first time a recorder is enabled.
+var flightOnce sync.Once
+
+// SetFlightRecorderOutput directs flight recorder dumps to be appended to the file at path (which
+// is created if necessary).  If path is "" then dumps are written to stderr (the default).
+// THREADING: This method is multi-thread safe.
+func SetFlightRecorderOutput(path string) {
+	flightLock.Lock()
+	flightOutput = path
+	flightLock.Unlock()
+}
+
+// registerFlightRecorder adds f to the set of open recorders.
+func registerFlightRecorder(f *FlightRecorder) {
+	flightOnce.Do(func() {
+		assert.AddFailureHook(func(message string) {
+			dumpAllFlightRecorders(message)
+		})
+		notifyFlightSignals()
+	})
+	flightLock.Lock()
+	flightRecorders[f] = true
+	flightLock.Unlock()
+}
+
+// dumpAllFlightRecorders dumps every open recorder.
+func dumpAllFlightRecorders(reason string) {
+	flightLock.Lock()
+	recorders := make([]*FlightRecorder, 0, len(flightRecorders))
+	for f := range flightRecorders {
+		recorders = append(recorders, f)
+	}
+	flightLock.Unlock()
+	dumpFlightRecorders(recorders, reason)
+}
+
+// dumpFlightRecorders writes recorders to the flight recorder output.
+func dumpFlightRecorders(recorders []*FlightRecorder, reason string) {
+	flightLock.Lock()
+	path := flightOutput
+	flightLock.Unlock()
+
+	var w io.Writer = os.Stderr
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Errorf("Failed to open flight recorder output %q: %v", path, err)
+		} else {
+			defer file.Close()
+			w = file
+		}
+	}
+	for _, f := range recorders {
+		if err := f.Dump(w, reason); err != nil {
+			log.Errorf("Failed to dump flight recorder %q: %v", f.name, err)
+		}
+	}
+}
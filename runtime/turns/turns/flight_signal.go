@@ -0,0 +1,38 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+//go:build !windows
+// +build !windows
+
+package turns
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyFlightSignals dumps all open flight recorders when the process receives SIGQUIT and then
+// re-raises the signal so that the runtime's default handler dumps the goroutines and exits.
+func notifyFlightSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGQUIT)
+	go func() {
+		<-c
+		dumpAllFlightRecorders("SIGQUIT")
+		signal.Reset(syscall.SIGQUIT)
+		syscall.Kill(os.Getpid(), syscall.SIGQUIT)
+	}()
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// FlightSuite is the test suite for the flight recorder.
+type FlightSuite struct {
+	test.Suite
+}
+
+// TestFlightSuite runs the test suite for the flight recorder.
+func TestFlightSuite(t *testing.T) {
+	test.RunSuite(t, new(FlightSuite))
+}
+
+// Ring verifies that the recorder keeps only the most recent events in order.
+func (t *FlightSuite) Ring() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+	f := m.EnableFlightRecorder("ring", 3)
+	defer f.Close()
+
+	_, s := async.NewR()
+	m.NewTurn("First", func() {})
+	m.NewTurn("Second", func() {
+		s.Fail(fmt.Errorf("boom"))
+	})
+	m.RunTurns(0)
+
+	var buf bytes.Buffer
+	if err := f.Dump(&buf, "test"); err != nil {
+		t.Fatalf("Expected dump to succeed.  Got: %v, Want: nil", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and 3 events.  Got: %q, Want: 4 lines", lines)
+	}
+	if !strings.Contains(lines[0], `flight recorder "ring" (test): last 3 events`) {
+		t.Errorf("Expected header.  Got: %q, Want: ring", lines[0])
+	}
+	want := []string{"run     First", "run     Second", "resolve Second"}
+	for i, w := range want {
+		if !strings.Contains(lines[i+1], w) {
+			t.Errorf("Expected event %d.  Got: %q, Want: %q", i, lines[i+1], w)
+		}
+	}
+	if !strings.HasSuffix(lines[3], " failed: boom") {
+		t.Errorf("Expected the failure.  Got: %q, Want: failed: boom", lines[3])
+	}
+}
+
+// assertionChild is set in the environment of a subprocess that fails an assertion.
+const assertionChild = "DRYDOCK_TEST_FLIGHT_ASSERTION"
+
+// Assertion verifies that the recorder is dumped to stderr when an assertion fails.  The failure
+// exits the process so it is run in a subprocess.
+func (t *FlightSuite) Assertion() {
+	if os.Getenv(assertionChild) != "" {
+		m := turns.NewManager(turns.NewUniqueIDGenerator())
+		m.EnableFlightRecorder("asserting", 0)
+		m.NewTurn("Asserting", func() {
+			assert.True(false, "deliberate failure")
+		})
+		m.RunTurns(0)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFlightSuite$")
+	cmd.Env = append(os.Environ(), assertionChild+"=1")
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Errorf("Expected the subprocess to fail.  Got: %v, Want: exit status", err)
+	}
+	if !strings.Contains(string(out),
+		`flight recorder "asserting" (precondition failed: deliberate failure)`) ||
+		!strings.Contains(string(out), "run     Asserting") {
+		t.Errorf("Expected dump of the failing turn.  Got: %s, Want: Asserting", out)
+	}
+}
+
+// Panic verifies that the recorder is dumped when a panic escapes a turn.
+func (t *FlightSuite) Panic() {
+	file, err := ioutil.TempFile("", "flight")
+	if err != nil {
+		t.Fatalf("Expected temp file.  Got: %v, Want: nil", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	turns.SetFlightRecorderOutput(file.Name())
+	defer turns.SetFlightRecorderOutput("")
+
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	f := m.EnableFlightRecorder("panicky", 0)
+	defer f.Close()
+	m.NewTurn("Explode", func() {
+		panic("boom")
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected the panic to continue.  Got: %v, Want: boom", r)
+			}
+		}()
+		m.RunTurns(0)
+	}()
+
+	dump, _ := ioutil.ReadFile(file.Name())
+	if !strings.Contains(string(dump), `flight recorder "panicky" (panic: boom)`) ||
+		!strings.Contains(string(dump), "run     Explode") {
+		t.Errorf("Expected dump of the panicking turn.  Got: %s, Want: Explode", dump)
+	}
+}
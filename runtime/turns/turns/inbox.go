@@ -0,0 +1,120 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the Inbox, a source through which code running outside of an actor (on any
+// goroutine) can schedule turns on the actor's manager.  Manager.NewTurn and the I/O sources MUST
+// only be used on the manager's thread; an Inbox is the supported way in from other threads.
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+
+	log "github.com/golang/glog"
+)
+
+// ErrInboxClosed is returned when posting to an Inbox that has been closed.
+var ErrInboxClosed = errors.New("inbox closed")
+
+// Inbox is a source of turns that may be posted from any goroutine.  Posted turns run on the
+// inbox's manager in the order they were posted.
+//
+// Posted turns are nondeterministic inputs to the actor: they are not captured by Record, and an
+// Inbox MUST NOT be used with Replay or SetIOScheduler.
+type Inbox struct {
+	// source delivers the posted turns to the manager.
+	source *turnSource
+
+	// lock protects closed.
+	lock sync.Mutex
+
+	// closed is true once the inbox has been closed.
+	closed bool
+}
+
+// NewInbox creates a new inbox whose turns run on the ambient (default) runner.  While the inbox is
+// open the manager always has a source from which turns may arrive, so it never reports a
+// DeadlockError.
+// THREADING: NewInbox MUST be called on the manager's thread.
+func NewInbox(name string) *Inbox {
+	return async.GetCurrentRunner().(*turnRunner).manager.NewInbox(name)
+}
+
+// NewInbox creates a new inbox whose turns run on the manager (see NewInbox).
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) NewInbox(name string) *Inbox {
+	return m.newInbox(name, false)
+}
+
+// NewDaemonInbox is like NewInbox but the inbox does not keep the manager alive: posted turns run
+// while the manager is waiting for other sources, but a manager whose only open sources are daemon
+// inboxes reports a DeadlockError.  Daemon inboxes are for observing a manager (e.g. by a debugger)
+// without changing its behavior.
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) NewDaemonInbox(name string) *Inbox {
+	return m.newInbox(name, true)
+}
+
+// newInbox creates a new inbox whose turns run on the manager.
+func (m *Manager) newInbox(name string, daemon bool) *Inbox {
+	i := &Inbox{
+		source: &turnSource{
+			manager: m,
+			name:    name,
+			label:   name,
+			list:    Empty,
+			daemon:  daemon,
+		},
+	}
+	log.V(3).Infof("NewInbox: %s", name)
+	i.source.event = m.registerSource(i.source)
+	return i
+}
+
+// Post schedules f to run in a new turn on the inbox's manager.  Returns ErrInboxClosed if the
+// inbox has been closed, in which case f will never run.
+// THREADING: This method is multi-thread safe.
+func (i *Inbox) Post(f func()) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.closed {
+		return ErrInboxClosed
+	}
+
+	// The turn is owned by the inbox rather than allocated from the manager's free list because
+	// allocTurn is not multi-thread safe.
+	turn := NewTurn("Post", f)
+	src := i.source
+	src.lock.Lock()
+	src.list = src.list.Append(turn)
+	src.lock.Unlock()
+	src.event.Signal()
+	return nil
+}
+
+// Close closes the inbox.  Turns posted but not yet run when the inbox is closed may never run.
+// THREADING: This method is multi-thread safe.
+func (i *Inbox) Close() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.closed {
+		return
+	}
+	i.closed = true
+	i.source.Close()
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains Inspect which takes a snapshot of a manager's state for diagnostics (e.g. by
+// the debug HTTP handler).
+
+import (
+	"sort"
+)
+
+// ManagerState is a snapshot of a manager's state.
+type ManagerState struct {
+	// Queue describes the turns queued to run at each priority (see Manager.String).
+	Queue string
+
+	// Queued is the number of turns queued to run.
+	Queued int
+
+	// Backlog is the number of I/O completions waiting to be moved onto the queue.
+	Backlog int
+
+	// Sources are the names of the open I/O sources registered with the manager, in order, if the
+	// manager tracks its sources (see TrackSources), otherwise nil.
+	Sources []string
+
+	// PendingResults is the number of unresolved results that have continuations waiting for them.
+	PendingResults int
+
+	// UnresolvedResults is the number of unresolved results if the manager is tracking results
+	// (see TrackResults), otherwise -1.
+	UnresolvedResults int
+
+	// Stats are the manager's loop statistics.
+	Stats LoopStats
+
+	// RecentEvents are the events recorded by the manager's flight recorder, oldest first (or nil
+	// if there is no flight recorder).  A Tracer (see SetTracer) streams its events to its writer
+	// without keeping any, so recent events always come from the flight recorder, which records the
+	// same turns in a bounded ring.
+	RecentEvents []string
+}
+
+// TrackSources causes the manager to keep the set of its open I/O sources so that they can be
+// reported by Inspect (debug.Register does this).  Sources are not tracked by default because
+// actors that open a source per I/O computation would pay to track each one.
+// TrackSources MUST be called before the manager starts running turns.
+func (m *Manager) TrackSources() {
+	if m.registered == nil {
+		m.registered = make(map[*turnSource]bool)
+	}
+}
+
+// Inspect returns a snapshot of the manager's state.  Inspect does not modify the manager.
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) Inspect() ManagerState {
+	state := ManagerState{
+		Queue:             m.String(),
+		Queued:            m.length(),
+		Backlog:           m.backlogLen,
+		UnresolvedResults: -1,
+		Stats:             m.stats,
+	}
+	if m.registered != nil {
+		state.Sources = []string{}
+		for source := range m.registered {
+			if !source.event.IsClosed() {
+				state.Sources = append(state.Sources, source.name)
+			}
+		}
+		sort.Strings(state.Sources)
+	}
+	for s := m.waiting; s != nil; s = s.waitNext {
+		state.PendingResults++
+	}
+	if m.trackResults {
+		state.UnresolvedResults = 0
+		for s := m.live; s != nil; s = s.live.next {
+			state.UnresolvedResults++
+		}
+	}
+	if m.flight != nil {
+		state.RecentEvents = m.flight.Events()
+	}
+	return state
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the controlled delivery of I/O completions used to systematically explore
+// the orders in which I/O can complete.  When an IOScheduler is installed the manager holds back
+// completed I/O until it has no turns left to run and all outstanding I/O computations have
+// completed (or, if a settle time is given, failed to complete within it).  The scheduler then
+// chooses which single completion is delivered next.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/base"
+	"github.com/prolang/drydock/runtime/turns/async"
+
+	log "github.com/golang/glog"
+)
+
+// IOCompletion describes a completed I/O computation waiting to be delivered.
+type IOCompletion struct {
+	// IO identifies the I/O computation by the order in which it was started on the manager.
+	IO int64
+
+	// Source is the name of the source on which the computation was started.
+	Source string
+
+	// Err is the error returned by the I/O function.
+	Err error
+}
+
+// IOScheduler chooses the order in which completed I/O computations are delivered.
+type IOScheduler interface {
+	// Choose returns the index in pending of the completion to deliver next.  pending is never
+	// empty and is ordered by IO.
+	Choose(pending []IOCompletion) int
+}
+
+// heldIO is a completed I/O computation whose delivery is being held for the IOScheduler.
+type heldIO struct {
+	// turn resolves the computation's result when run.
+	turn *Turn
+
+	// completion describes the computation.
+	completion IOCompletion
+}
+
+// SetIOScheduler installs s to choose the order in which I/O completions are delivered.  If settle
+// is zero then each choice waits until every outstanding I/O computation has completed, so the
+// choices offered depend only on the turns run and not on timing.  Otherwise each choice waits at
+// most settle for outstanding I/O (which allows for I/O that never completes on its own, at the
+// cost of determinism).  SetIOScheduler MUST be called before the manager starts running turns.
+func (m *Manager) SetIOScheduler(s IOScheduler, settle time.Duration) {
+	m.ioScheduler, m.settle = s, settle
+}
+
+// NewNamedTurnSource is like NewTurnSource but gives the source a name that identifies its I/O
+// completions to an IOScheduler.  The name is also the label under which the source's I/O is
+// counted in the manager's metrics, so names SHOULD be drawn from a small fixed set.
+func NewNamedTurnSource(name string) async.Source {
+	manager := async.GetCurrentRunner().(*turnRunner).manager
+	t := &turnSource{
+		manager: manager,
+		name:    name,
+		label:   name,
+		lock:    sync.Mutex{},
+		list:    Empty,
+	}
+	log.V(3).Infof("NewNamedTurnSource: %s", t.name)
+	t.event = t.manager.registerSource(t)
+	return t
+}
+
+// hold adds completed I/O from source to the set held for the scheduler.
+func (m *Manager) hold(source string, list *Turn, completions []ioCompletion) {
+	var head *Turn
+	for _, c := range completions {
+		head, list = list.RemoveHead()
+		held := heldIO{
+			turn: head,
+			completion: IOCompletion{
+				IO:     c.seq,
+				Source: source,
+				Err:    c.err,
+			},
+		}
+
+		// Keep the held completions ordered by IO.
+		i := len(m.held)
+		m.held = append(m.held, held)
+		for ; (i > 0) && (m.held[i-1].completion.IO > c.seq); i-- {
+			m.held[i] = m.held[i-1]
+		}
+		m.held[i] = held
+	}
+}
+
+// waitSettled blocks until every outstanding I/O computation has completed (or no completion has
+// arrived for the settle time, if any), and then allows the scheduler to make its next choice.
+func (m *Manager) waitSettled() {
+	for m.outstanding > 0 {
+		var e *base.Event
+		if m.settle > 0 {
+			e = m.waitTimeout(m.settle)
+		} else {
+			e = m.wait()
+		}
+		if e == nil {
+			break
+		}
+		e.Signal() // force Select in ingestIO to see this source again.
+		m.ingestIO(time.Now())
+	}
+	m.settled = true
+}
+
+// deliverHeld queues the completion chosen by the scheduler if the manager has settled.
+func (m *Manager) deliverHeld() {
+	if !m.settled || (len(m.held) == 0) || (m.length() > 0) {
+		return
+	}
+	m.settled = false
+
+	pending := make([]IOCompletion, len(m.held))
+	for i, h := range m.held {
+		pending[i] = h.completion
+	}
+	i := m.ioScheduler.Choose(pending)
+	t := m.held[i].turn
+	m.held = append(m.held[:i], m.held[i+1:]...)
+	m.Queue(t)
+	m.stats.IOTurns++
+}
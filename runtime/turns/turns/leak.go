@@ -0,0 +1,121 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the leak detector.  A result that is never resolved silently holds the
+// continuations registered on it forever, so the computations that would have followed it never
+// complete.  When enabled, a manager keeps a registry of every unresolved result it has created so
+// that orphaned results can be reported at any time (typically when the actor exits, at which
+// point every result it created should have been resolved).
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// liveResult is the registry entry of an unresolved result.
+type liveResult struct {
+	// created is when the result was created according to the manager's clock.
+	created time.Time
+
+	// prev and next link the result into the manager's registry.
+	prev, next *turnResolver
+}
+
+// UnresolvedResult describes a result that has not (yet) been resolved.
+type UnresolvedResult struct {
+	// Site is where the result was created.
+	Site CallSite
+
+	// Created is when the result was created according to the manager's clock.
+	Created time.Time
+
+	// Age is how long the result has been unresolved.
+	Age time.Duration
+
+	// Continuations are the names of the turns waiting for the result, in the order they will run.
+	Continuations []string
+}
+
+// String implements fmt.Stringer.
+func (u UnresolvedResult) String() string {
+	return fmt.Sprintf("result created at %v unresolved for %v, waiting: [%s]", u.Site, u.Age,
+		strings.Join(u.Continuations, ", "))
+}
+
+// TrackResults causes the manager to keep a registry of the results it creates until they are
+// resolved (or forwarded) so that they can be reported by UnresolvedResults.  Tracking also records
+// call sites (see RecordCallSites).
+// TrackResults MUST be called before the manager starts running turns.
+func (m *Manager) TrackResults() {
+	m.trackResults = true
+	m.callSites = true
+}
+
+// IsTrackingResults returns true if the manager keeps a registry of unresolved results.
+func (m *Manager) IsTrackingResults() bool {
+	return m.trackResults
+}
+
+// UnresolvedResults returns the results created by the manager that are still unresolved, oldest
+// first.  Returns nil if the manager is not tracking results.
+// THREADING: This method MUST only be called on the manager's thread (or after it has stopped).
+func (m *Manager) UnresolvedResults() []UnresolvedResult {
+	var result []UnresolvedResult
+	now := m.clock.Now()
+	for s := m.live; s != nil; s = s.live.next {
+		result = append(result, UnresolvedResult{
+			Site:          s.sites.callSite(),
+			Created:       s.live.created,
+			Age:           now.Sub(s.live.created),
+			Continuations: s.continuations(),
+		})
+	}
+
+	// Results are added to the front of the registry, so reverse it to put the oldest first.
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// addLive adds a newly created result to the manager's registry.
+func (s *turnResolver) addLive() {
+	m := s.manager
+	s.live = &liveResult{
+		created: m.clock.Now(),
+		next:    m.live,
+	}
+	if m.live != nil {
+		m.live.live.prev = s
+	}
+	m.live = s
+}
+
+// removeLive removes a result that has been resolved or forwarded from the manager's registry.
+func (s *turnResolver) removeLive() {
+	m := s.manager
+	if s.live.prev != nil {
+		s.live.prev.live.next = s.live.next
+	} else {
+		m.live = s.live.next
+	}
+	if s.live.next != nil {
+		s.live.next.live.prev = s.live.prev
+	}
+	s.live = nil
+}
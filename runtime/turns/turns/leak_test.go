@@ -0,0 +1,94 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// LeakSuite is the test suite for the leak detector.
+type LeakSuite struct {
+	test.Suite
+}
+
+// TestLeakSuite runs the test suite for the leak detector.
+func TestLeakSuite(t *testing.T) {
+	test.RunSuite(t, new(LeakSuite))
+}
+
+// ReportsOrphans verifies that unresolved results are reported with their continuations and that
+// resolved and forwarded results are not.
+func (t *LeakSuite) ReportsOrphans() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	m.TrackResults()
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	main := async.New(func() async.R {
+		orphan, _ := async.NewR()
+		async.When(orphan, func() {})
+
+		resolved, s := async.NewR()
+		s.Complete()
+		return resolved
+	})
+	if err := m.RunUntil(main); err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+
+	leaks := m.UnresolvedResults()
+	if len(leaks) != 2 {
+		t.Fatalf("Expected the orphan and its When.  Got: %v, Want: 2 results", leaks)
+	}
+	orphan, when := leaks[0], leaks[1]
+	if len(orphan.Continuations) != 1 || !strings.HasPrefix(orphan.Continuations[0], "When") {
+		t.Errorf("Expected the orphan's continuation.  Got: %v, Want: [When...]", orphan)
+	}
+	if len(when.Continuations) != 0 {
+		t.Errorf("Expected no continuations.  Got: %v, Want: none", when)
+	}
+	for _, u := range leaks {
+		if !strings.HasSuffix(u.Site.File, "leak_test.go") {
+			t.Errorf("Expected the creation site.  Got: %v, Want: leak_test.go", u.Site)
+		}
+		if u.Created.IsZero() || u.Age < 0 {
+			t.Errorf("Expected an age.  Got: %v, Want: >= 0", u)
+		}
+	}
+}
+
+// Disabled verifies that nothing is tracked unless requested.
+func (t *LeakSuite) Disabled() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	main := async.New(func() async.R {
+		async.NewR()
+		return async.Done()
+	})
+	if err := m.RunUntil(main); err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if leaks := m.UnresolvedResults(); leaks != nil {
+		t.Errorf("Expected no tracking.  Got: %v, Want: nil", leaks)
+	}
+}
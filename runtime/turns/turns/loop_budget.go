@@ -0,0 +1,160 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+import "time"
+
+// LoopBudget limits the work done by a single iteration of a Manager's turn loop so that neither
+// local turns nor I/O completions can delay the other indefinitely.  A zero value for any limit
+// means that limit is not enforced.
+type LoopBudget struct {
+	// MaxTurns is the maximum number of turns run in a single loop.  Turns beyond the limit remain
+	// queued for the next loop.
+	MaxTurns int
+
+	// MaxIOTurns is the maximum number of I/O completions moved onto the main queue in a single
+	// loop.  Completions beyond the limit are held in a backlog (in arrival order) for the next loop.
+	MaxIOTurns int
+
+	// MaxDuration is the maximum time spent running turns in a single loop.  The limit is checked
+	// between turns so a single long turn may exceed it.
+	MaxDuration time.Duration
+}
+
+// LoopStats are counters describing the behavior of a Manager's turn loop.  They can be used to
+// tune a LoopBudget.
+type LoopStats struct {
+	// Loops is the number of loop iterations run.
+	Loops int64
+
+	// Turns is the number of turns run by loop iterations.
+	Turns int64
+
+	// IOTurns is the number of I/O completions moved onto the main queue.
+	IOTurns int64
+
+	// TurnLimited is the number of loops that left runnable turns queued because of MaxTurns or
+	// MaxDuration.
+	TurnLimited int64
+
+	// IOLimited is the number of loops that left I/O completions in the backlog because of
+	// MaxIOTurns.
+	IOLimited int64
+
+	// MaxIOBacklog is the largest number of I/O completions ever waiting in the backlog.
+	MaxIOBacklog int
+
+	// MaxIODelay is the longest time an I/O completion waited in the backlog before being moved onto
+	// the main queue.
+	MaxIODelay time.Duration
+
+	// MaxLoopDuration is the longest time taken by a single loop iteration.
+	MaxLoopDuration time.Duration
+}
+
+// ioBatch is a group of I/O completions that were ingested into the backlog at the same time.
+type ioBatch struct {
+	// at is the time at which the completions were ingested.
+	at time.Time
+
+	// count is the number of completions from the batch still in the backlog.
+	count int
+}
+
+// SetLoopBudget sets the limits on the work done by a single loop iteration.  The default budget
+// has no limits.
+func (m *Manager) SetLoopBudget(budget LoopBudget) {
+	m.budget = budget
+}
+
+// Stats returns a snapshot of the manager's loop statistics.
+func (m *Manager) Stats() LoopStats {
+	return m.stats
+}
+
+// ingestIO moves all turns from signalled I/O sources onto the backlog.
+func (m *Manager) ingestIO(now time.Time) {
+	count := 0
+	if m.replay != nil {
+		count = m.replay.ingest(m)
+	}
+	for e := m.sources.Select(); e != nil; e = m.sources.Select() {
+		var head *Turn
+		source := e.Data().(*turnSource)
+		list, completions := source.getAllTurns()
+		if m.metrics != nil {
+			m.metrics.addIO(source.label, list.count())
+		}
+		if m.tracer != nil {
+			m.traceIngest(source.name, list)
+		}
+		if m.flight != nil {
+			m.flight.recordIngest(source.name, list)
+		}
+		m.outstanding -= len(completions)
+		if m.recorder != nil {
+			m.record(completions)
+		}
+		if m.ioScheduler != nil {
+			m.hold(source.name, list, completions)
+			continue
+		}
+		for !list.IsEmpty() {
+			head, list = list.RemoveHead()
+			m.backlog = m.backlog.Append(head)
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	m.batches = append(m.batches, ioBatch{at: now, count: count})
+	m.backlogLen += count
+	if m.backlogLen > m.stats.MaxIOBacklog {
+		m.stats.MaxIOBacklog = m.backlogLen
+	}
+}
+
+// queueIO moves I/O completions from the backlog onto the main queue, in arrival order, subject to
+// the budget's MaxIOTurns.
+func (m *Manager) queueIO(now time.Time) {
+	n := m.backlogLen
+	if m.random != nil {
+		n = m.randomIOCount(n)
+	}
+	if (m.budget.MaxIOTurns > 0) && (n > m.budget.MaxIOTurns) {
+		n = m.budget.MaxIOTurns
+		m.stats.IOLimited++
+	}
+
+	var head *Turn
+	for ; n > 0; n-- {
+		head, m.backlog = m.backlog.RemoveHead()
+		m.backlogLen--
+		m.Queue(head)
+		head.cause = m.newCause()
+		m.stats.IOTurns++
+
+		batch := &m.batches[0]
+		if delay := now.Sub(batch.at); delay > m.stats.MaxIODelay {
+			m.stats.MaxIODelay = delay
+		}
+		if batch.count--; batch.count == 0 {
+			m.batches = m.batches[1:]
+		}
+	}
+}
@@ -16,8 +16,13 @@
 package turns
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
 
 	"github.com/prolang/drydock/runtime/base/assert"
 	"github.com/prolang/drydock/runtime/base/base"
@@ -30,8 +35,149 @@ type Manager struct {
 	// sources is the set of I/O sources from which asynchronous turns may arrive.
 	sources *base.EventSet
 
-	// turns is the main queue of turns to be run by this manager in FIFO order.
-	turns *Turn
+	// open is the number of open sources that are not daemons.  Accessed atomically because sources
+	// may be closed on any thread.
+	open int32
+
+	// daemons is the number of daemon sources that have been registered (see NewDaemonInbox).
+	daemons int
+
+	// registered (if not nil) is the set of sources registered with the manager (see TrackSources).
+	// Closed sources are removed lazily (see pruneSources).
+	registered map[*turnSource]bool
+
+	// pruneAt is the size of registered at which closed sources are next removed.
+	pruneAt int
+
+	// turns are the main queues of turns to be run by this manager, one per priority, each in FIFO
+	// order.
+	turns [async.NumPriorities]*Turn
+
+	// lengths is the number of turns in each of the main queues.
+	lengths [async.NumPriorities]int
+
+	// policy determines how turns are chosen from the main queues.
+	policy PriorityPolicy
+
+	// credits is the number of turns of each priority remaining in the current weighted round.
+	credits [async.NumPriorities]int
+
+	// backlog is the queue of I/O completions that have been ingested from sources but not yet moved
+	// onto the main queues because of the loop budget.
+	backlog *Turn
+
+	// backlogLen is the number of turns in the backlog.
+	backlogLen int
+
+	// batches records when the turns in the backlog were ingested, oldest first.
+	batches []ioBatch
+
+	// budget limits the work done by each loop iteration.
+	budget LoopBudget
+
+	// stats are the loop statistics.
+	stats LoopStats
+
+	// free is a list of turns that have run and can be reused by allocTurn.
+	free []*Turn
+
+	// unpooled is true if turns are allocated individually (see DisablePooling).
+	unpooled bool
+
+	// ioSeq is the sequence number of the last I/O computation started on the manager.
+	ioSeq int64
+
+	// recorder (if not nil) records I/O completions.
+	recorder *json.Encoder
+
+	// replay (if not nil) replays recorded I/O completions in place of executing I/O.
+	replay *replayer
+
+	// random (if not nil) chooses the order of ready turns and I/O arrivals.
+	random *rand.Rand
+
+	// causes is the last cause allocated by newCause.
+	causes uint64
+
+	// running is the cause of the turns queued by the turn currently running (or zero).
+	running uint64
+
+	// outstanding is the number of I/O computations started but not yet ingested.
+	outstanding int
+
+	// ioScheduler (if not nil) chooses the order in which I/O completions are delivered.
+	ioScheduler IOScheduler
+
+	// settle (if non-zero) bounds the time to wait for outstanding I/O before the ioScheduler makes a
+	// choice.
+	settle time.Duration
+
+	// held are the completions waiting for the ioScheduler, ordered by IO.
+	held []heldIO
+
+	// settled is true if the ioScheduler may choose the next completion to deliver.
+	settled bool
+
+	// clock is the source of time for timers.
+	clock Clock
+
+	// clockSource (if not nil) is signalled when an explicitly advanced FakeClock is advanced.  It is
+	// only registered while there are timers waiting for the clock.
+	clockSource *turnSource
+
+	// timers are the timers waiting for their deadline.
+	timers timerHeap
+
+	// timerSeq is the sequence number of the last timer created.
+	timerSeq uint64
+
+	// metrics (if not nil) are the runtime metrics collected by the manager.
+	metrics *Metrics
+
+	// flight (if not nil) records the manager's most recent events.
+	flight *FlightRecorder
+
+	// profile (if not nil) are the profile labels applied while running turns.
+	profile *profileLabels
+
+	// watchdog (if not nil) detects turns that run for too long.
+	watchdog *Watchdog
+
+	// tracer (if not nil) receives an event for every turn run.
+	tracer *Tracer
+
+	// traceTID is the manager's thread ID in the tracer.
+	traceTID int
+
+	// traced records the cause of each queued turn while tracing.
+	traced map[*Turn]tracedTurn
+
+	// tracePruneAt is the size of traced at which abandoned I/O is next removed.
+	tracePruneAt int
+
+	// span is the current span (or nil).
+	span *Span
+
+	// spans (if not nil) receives spans when they end.
+	spans *SpanExporter
+
+	// waiting is the list of unresolved results that have continuations, newest first.
+	waiting *turnResolver
+
+	// callSites is true if the stack at which each result is created is recorded.
+	callSites bool
+
+	// trackResults is true if the manager keeps a registry of unresolved results.
+	trackResults bool
+
+	// live is the registry of unresolved results, newest first.
+	live *turnResolver
+
+	// closers are called in reverse order when the manager is closed (see OnClose).
+	closers []func()
+
+	// runner is the manager's async.Runner (see NewTurnRunner).
+	runner *turnRunner
 
 	// idgen generates new unique ids.
 	idgen *UniqueIDGenerator
@@ -39,11 +185,17 @@ type Manager struct {
 
 // NewManager creates a new turn manager.
 func NewManager(idgen *UniqueIDGenerator) *Manager {
-	return &Manager{
+	m := &Manager{
 		sources: base.NewEventSet(),
-		turns:   Empty,
+		backlog: Empty,
+		policy:  StrictPriority(),
+		clock:   RealClock(),
 		idgen:   idgen,
 	}
+	for i := range m.turns {
+		m.turns[i] = Empty
+	}
+	return m
 }
 
 // NewID generates a new ID.  ID's are never reused.
@@ -56,33 +208,174 @@ func (m *Manager) String() string {
 	return fmt.Sprintf("%v", m.turns)
 }
 
-// Queue adds an existing turn to the managers queue.
+// OnClose registers f to be called when the manager is closed.  Closers are called in the reverse
+// order to which they were registered.
+func (m *Manager) OnClose(f func()) {
+	m.closers = append(m.closers, f)
+}
+
+// Close releases the resources attached to the manager (e.g. its watchdog and flight recorder).
+// An actor closes its manager when it exits.  The manager MUST NOT run turns after it is closed.
+func (m *Manager) Close() {
+	closers := m.closers
+	m.closers = nil
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+}
+
+// SetPriorityPolicy determines how the manager chooses between runnable turns of different
+// priorities.  The default policy is StrictPriority.
+func (m *Manager) SetPriorityPolicy(policy PriorityPolicy) {
+	m.policy = policy
+	m.credits = [async.NumPriorities]int{}
+}
+
+// Queue adds an existing turn to the managers queue for the turn's priority.
 // REQUIRES: t is NOT already in any turn list or queue.
 func (m *Manager) Queue(t *Turn) {
-	m.turns = m.turns.Append(t)
+	p := t.priority
+	assert.True(p.IsValid(), "Invalid priority: %v", p)
+	m.turns[p] = m.turns[p].Append(t)
+	m.lengths[p]++
+	t.manager = m
+	t.cause = m.running
+	if m.tracer != nil {
+		m.traceQueue(t)
+	}
+	if m.flight != nil {
+		m.flight.record(flightQueue, t.label, t.id, "")
+	}
 }
 
 // NewTurn creates a new turn that will call f() when it is executed.  Adds the turn to the
 // managers queue.
 func (m *Manager) NewTurn(name string, f func()) {
-	t := NewTurn(name, f)
-	m.turns = m.turns.Append(t)
+	m.Queue(m.allocTurn(name, UniqueID{}, async.PriorityNormal, f))
+}
+
+// NewTurnWithPriority creates a new turn that will call f() when it is executed at priority p.
+// Adds the turn to the managers queue.
+func (m *Manager) NewTurnWithPriority(name string, p async.Priority, f func()) {
+	m.Queue(m.allocTurn(name, UniqueID{}, p, f))
+}
+
+// maxFreeTurns bounds the number of turns kept on a manager's free list.
+const maxFreeTurns = 1024
+
+// DisablePooling makes the manager allocate every turn individually instead of from its free
+// list.  Pooling hides each object's true lifetime, so disabling it can help memory debugging
+// tools, and it provides the baseline against which pooling is measured.
+// DisablePooling MUST be called before the manager starts running turns.
+func (m *Manager) DisablePooling() {
+	m.unpooled = true
+	m.free = nil
+}
+
+// allocTurn returns a single item turn, reusing a previously run turn if one is available.  The
+// turn's name is label followed by id (if id is non-zero).  The turn is returned to the free list
+// after it runs so callers MUST NOT retain it.
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) allocTurn(label string, id UniqueID, p async.Priority, f func()) *Turn {
+	n := len(m.free)
+	if n == 0 {
+		t := NewTurnWithPriority(label, p, f)
+		t.id, t.pooled, t.span = id, true, m.span
+		return t
+	}
+
+	t := m.free[n-1]
+	m.free[n-1] = nil
+	m.free = m.free[:n-1]
+	t.f, t.label, t.id, t.priority, t.span = f, label, id, p, m.span
+	return t
+}
+
+// run runs a single turn and then returns it to the free list if it was allocated by allocTurn.
+func (m *Manager) run(t *Turn) {
+	previous, span := m.running, m.span
+	m.running, m.span = m.newCause(), t.span
+	var start time.Time
+	if m.metrics != nil {
+		start = time.Now()
+	}
+	if m.watchdog != nil {
+		m.watchdog.begin(t)
+	}
+	var outer *Turn
+	if m.flight != nil {
+		outer = m.flight.begin(t)
+	}
+	var labels context.Context
+	if m.profile != nil {
+		labels = m.labelTurn(t)
+	}
+	if m.tracer != nil {
+		m.traceRun(t, m.running)
+	} else {
+		t.Run()
+	}
+	if m.profile != nil {
+		m.restoreLabels(labels)
+	}
+	if m.watchdog != nil {
+		m.watchdog.end()
+	}
+	if m.flight != nil {
+		m.flight.end(outer)
+	}
+	if m.metrics != nil {
+		m.metrics.TurnDuration.Observe(time.Since(start))
+		m.metrics.TurnsRun.Add(1)
+	}
+	m.running, m.span = previous, span
+
+	if t.pooled && !m.unpooled && (len(m.free) < maxFreeTurns) {
+		// Drop references held by the turn so that they can be collected.
+		t.f, t.label, t.span = nil, "", nil
+		m.free = append(m.free, t)
+	}
 }
 
 // Unlink removes a turn from the manager.
 // The turn may appear anywhere in the managers queue including the middle.
-// Note: This is potentially an O(n) operation in the length of the queue.
-// REQUIRES: the turn MUST be in the queue.
+// REQUIRES: the turn MUST be in the queue or in no list at all.
 func (m *Manager) Unlink(t *Turn) {
-	m.turns = m.turns.Unlink(t)
+	if !t.IsList() {
+		return
+	}
+	assert.True(t.manager == m, "Expected turn %v to be queued on this manager.", t)
+	p := t.priority
+	m.turns[p] = m.turns[p].Unlink(t)
+	m.lengths[p]--
+	t.manager = nil
+	if m.tracer != nil {
+		delete(m.traced, t)
+	}
+}
+
+// Dequeue removes a pending turn from the manager's queue so that it will not run.  Returns true
+// if the turn was removed, or false if the turn is not queued on this manager (e.g. because it has
+// already run, was never queued, or is waiting elsewhere such as on an unresolved result).
+// Dequeue is an O(1) operation.
+func (m *Manager) Dequeue(t *Turn) bool {
+	if t.IsEmpty() || (t.manager != m) {
+		return false
+	}
+	m.Unlink(t)
+	return true
 }
 
 // errSuccess is a sentinel value used to identify a successful exit.
 var errSuccess = errors.New("main exited successfully")
 
 // RunUntil runs turns in the manager until main becomes resolved.  If main fails, then its error
-// is returned, otherwise returns nil.
+// is returned, otherwise returns nil.  If main can never become resolved because there is no work
+// left to do then a *DeadlockError is returned.
 func (m *Manager) RunUntil(main async.R) error {
+	if m.flight != nil {
+		defer m.flight.dumpOnPanic()
+	}
 	var mainExited error
 	m.NewTurn("RunUntil", func() {
 		async.When(main, func(err error) error {
@@ -101,13 +394,38 @@ func (m *Manager) RunUntil(main async.R) error {
 		// Flush the main queue.
 		m.runOneLoop()
 
+		// During replay I/O completes from the recording rather than by blocking on sources.
+		if m.replay != nil {
+			if m.replay.err != nil {
+				return m.replay.err
+			}
+			if m.isIdle() && (mainExited == nil) && m.replay.exhausted() {
+				return ErrReplayExhausted
+			}
+			continue
+		}
+
+		// If I/O delivery is being scheduled then let the scheduler choose once I/O has settled.
+		if m.isIdle() && (mainExited == nil) && (len(m.held) > 0) {
+			m.waitSettled()
+			continue
+		}
+
+		// If there is no work to do until a timer fires then wait for it.
+		if m.isIdle() && (mainExited == nil) && m.waitForTimer() {
+			continue
+		}
+
 		// If there is no work to do then block on I/O.
-		if m.turns.IsEmpty() && (mainExited == nil) {
-			e := m.sources.Wait()
-			assert.True(m.turns.IsEmpty(), "Only blocked on I/O if there was no work to do.")
+		if m.isIdle() && (mainExited == nil) {
+			e := m.wait()
+			assert.True(m.isIdle(), "Only blocked on I/O if there was no work to do.")
 			assert.True(mainExited == nil, "Only blocked on I/O if the program not exited.")
-			assert.True(e != nil, "LIVE LOCK: no progress can be made because there are no I/O "+
-				"sources,	no local turns, and the program has not yet exited.")
+			if e == nil {
+				// No progress can be made because there are no I/O sources, no local turns, and the
+				// program has not yet exited.
+				return m.deadlock()
+			}
 			e.Signal() // force Select in next loop to see this source again.
 		}
 	}
@@ -118,46 +436,107 @@ func (m *Manager) RunUntil(main async.R) error {
 	return nil
 }
 
-// RunOneTurn runs a single turn from the main queue if any exist.  Return true if a turn was run.
+// RunOneTurn runs a single turn from the main queues if any exist.  Return true if a turn was run.
 func (m *Manager) RunOneTurn() bool {
-	var t *Turn
-	if !m.turns.IsEmpty() {
-		t, m.turns = m.turns.RemoveHead()
-		t.Run()
+	if t := m.next(); t != nil {
+		m.run(t)
 		return true
 	}
 	return false
 }
 
 // runOneLoop runs a single iteration of the turn loop which includes both executing turns on the
-// main queue at the time of the call and checking for new turns from asynchronous sources.
+// main queue at the time of the call and checking for new turns from asynchronous sources.  The
+// work done is limited by the manager's loop budget.
 func (m *Manager) runOneLoop() {
-	// Check for async I/O turns and append them to the main queue before snapshotting.
-	for e := m.sources.Select(); e != nil; e = m.sources.Select() {
-		var head *Turn
-		list := e.Data().(*turnSource).getAllTurns()
-		for !list.IsEmpty() {
-			head, list = list.RemoveHead()
-			m.turns = m.turns.Append(head)
+	start := time.Now()
+	m.stats.Loops++
+
+	// Check for expired timers and async I/O turns and append them to the main queue before
+	// counting the turns to run.
+	m.poll(start)
+
+	// Run as many turns as are on the main queues at the start of the loop.  Executing these turns
+	// may enqueue more turns on the main queues but won't increase the number of turns run in this
+	// loop.  With a single priority this runs exactly a snapshot of the main queue; with multiple
+	// priorities a newly queued turn may run ahead of an older turn of lower priority.
+	n := m.length()
+	limited := false
+	if (m.budget.MaxTurns > 0) && (n > m.budget.MaxTurns) {
+		n = m.budget.MaxTurns
+		limited = true
+	}
+	for ; n > 0; n-- {
+		t := m.next()
+		if t == nil {
+			break
 		}
+		m.run(t)
+		m.stats.Turns++
+
+		// Always run at least one turn so that the loop makes progress.
+		if (m.budget.MaxDuration > 0) && (n > 1) && (time.Since(start) >= m.budget.MaxDuration) {
+			limited = true
+			break
+		}
+	}
+	if limited && !m.isIdle() {
+		m.stats.TurnLimited++
+	}
+
+	if m.metrics != nil {
+		m.metrics.QueueDepth.Set(int64(m.length() + m.backlogLen + len(m.held)))
+	}
+	if d := time.Since(start); d > m.stats.MaxLoopDuration {
+		m.stats.MaxLoopDuration = d
+	}
+}
+
+// poll moves expired timers and completed I/O onto the main queues (subject to the loop budget).
+func (m *Manager) poll(now time.Time) {
+	m.fireTimers()
+	m.ingestIO(now)
+	if m.ioScheduler != nil {
+		m.deliverHeld()
+	} else {
+		m.queueIO(now)
+	}
+}
+
+// next removes and returns the next turn to run according to the manager's priority policy.
+// Returns nil if there are no turns to run.
+func (m *Manager) next() *Turn {
+	p, ok := m.policy.choose(&m.lengths, &m.credits)
+	if !ok {
+		return nil
 	}
 
-	// Snapshot the main queue.  Executing these turns may enqueue more turns on the main queue but
-	// they won't be part of this loop (but will be part of the next loop).
-	list := m.turns
-	m.turns = Empty
+	if m.random != nil {
+		t := m.pickRandom(m.turns[p])
+		m.Unlink(t)
+		return t
+	}
 
-	// Run a full cycle of the main queue snapshot.
 	var t *Turn
-	for !list.IsEmpty() {
-		t, list = list.RemoveHead()
-		t.Run()
+	t, m.turns[p] = m.turns[p].RemoveHead()
+	m.lengths[p]--
+	t.manager = nil
+	return t
+}
+
+// length returns the number of turns on the main queues.
+func (m *Manager) length() int {
+	n := 0
+	for _, l := range m.lengths {
+		n += l
 	}
+	return n
 }
 
-// isIdle returns true if the manager's main queue has no turns to run, otherwise false.
+// isIdle returns true if the manager has no turns to run (either on the main queues or in the I/O
+// backlog), otherwise false.
 func (m *Manager) isIdle() bool {
-	return m.turns.IsEmpty()
+	return (m.length() == 0) && (m.backlogLen == 0)
 }
 
 // registerSource adds an asynchronous source of turns to the set tracked by this manager.
@@ -172,5 +551,57 @@ func (m *Manager) registerSource(source *turnSource) *base.Event {
 	// Add the event to the set of source to track.  Closing the event will automatically unregister
 	// the source during the main turn loop's Select call.
 	m.sources.Add(event)
+	if source.daemon {
+		m.daemons++
+	} else {
+		atomic.AddInt32(&m.open, 1)
+	}
+	if m.metrics != nil {
+		m.metrics.openSource(source.label)
+	}
+	if m.registered != nil {
+		if len(m.registered) >= m.pruneAt {
+			m.pruneSources()
+		}
+		m.registered[source] = true
+	}
 	return event
 }
+
+// waitSources blocks until a source is signalled and returns it (see EventSet.Wait).  Returns nil
+// if there are no sources from which turns could arrive other than daemons.
+func (m *Manager) waitSources() *base.Event {
+	if m.daemons == 0 {
+		return m.sources.Wait()
+	}
+	for {
+		// A source is signalled before it is closed, so if no source was open before selecting then
+		// the select sees every turn that will ever arrive.
+		closed := atomic.LoadInt32(&m.open) == 0
+		if e := m.sources.Select(); e != nil {
+			return e
+		}
+		if closed {
+			return nil
+		}
+		<-m.sources.Wake()
+	}
+}
+
+// pruneSources removes closed sources from the set of registered sources.  Pruning is deferred
+// until the set has doubled in size so that its cost is amortized over the registrations.
+func (m *Manager) pruneSources() {
+	for source := range m.registered {
+		if source.event.IsClosed() {
+			delete(m.registered, source)
+		}
+	}
+	m.pruneAt = 2 * len(m.registered)
+	if m.pruneAt < minPruneAt {
+		m.pruneAt = minPruneAt
+	}
+}
+
+// minPruneAt is the smallest size of the set of registered sources at which closed sources are
+// removed.
+const minPruneAt = 16
@@ -17,7 +17,10 @@ package turns
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/prolang/drydock/runtime/base/test"
 	"github.com/prolang/drydock/runtime/turns/async"
@@ -92,7 +95,7 @@ func (t *ManagerSuite) RunUntilSuccess() {
 
 	// Allocate a resolver to use as the "main" result for RunUntil.
 	s := newTurnResolver(m)
-	r := async.R{async.NewResultT(s)}
+	r := async.R{ResultT: async.NewResultT(s)}
 
 	m.Queue(NewTurn("main", func() {
 		s.Complete(nil)
@@ -110,7 +113,7 @@ func (t *ManagerSuite) RunUntilFailed() {
 
 	// Allocate a resolver to use as the "main" result for RunUntil.
 	s := newTurnResolver(m)
-	r := async.R{async.NewResultT(s)}
+	r := async.R{ResultT: async.NewResultT(s)}
 
 	expectedError := errors.New("Expected failure")
 	m.Queue(NewTurn("main", func() {
@@ -163,3 +166,438 @@ func (t *ManagerSuite) Unlink() {
 		t.Fatalf("Expected turn to NOT have executed.  Got: %v, Want: %v", didRun, false)
 	}
 }
+
+// recorder returns a function that appends name to order when called.
+func recorder(order *[]string, name string) func() {
+	return func() {
+		*order = append(*order, name)
+	}
+}
+
+func (t *ManagerSuite) StrictPriority() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	var order []string
+	m.NewTurnWithPriority("low1", async.PriorityLow, recorder(&order, "low1"))
+	m.NewTurnWithPriority("normal1", async.PriorityNormal, recorder(&order, "normal1"))
+	m.NewTurnWithPriority("high1", async.PriorityHigh, recorder(&order, "high1"))
+	m.NewTurnWithPriority("low2", async.PriorityLow, recorder(&order, "low2"))
+	m.NewTurnWithPriority("high2", async.PriorityHigh, recorder(&order, "high2"))
+	m.runOneLoop()
+
+	expected := []string{"high1", "high2", "normal1", "low1", "low2"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected priority order.  Got: %v, Want: %v", order, expected)
+	}
+	if !m.isIdle() {
+		t.Errorf("Expected empty manager.  Got: %v, Want: %v", m, "empty")
+	}
+}
+
+func (t *ManagerSuite) HighPriorityPreempts() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	// A high priority turn queued during a loop runs ahead of older low priority turns, but the loop
+	// still runs no more turns than were queued when it started.
+	var order []string
+	m.NewTurnWithPriority("low1", async.PriorityLow, recorder(&order, "low1"))
+	m.NewTurnWithPriority("normal", async.PriorityNormal, func() {
+		order = append(order, "normal")
+		m.NewTurnWithPriority("high", async.PriorityHigh, recorder(&order, "high"))
+	})
+	m.NewTurnWithPriority("low2", async.PriorityLow, recorder(&order, "low2"))
+	m.runOneLoop()
+
+	expected := []string{"normal", "high", "low1"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected high priority first.  Got: %v, Want: %v", order, expected)
+	}
+	m.runOneLoop()
+	expected = append(expected, "low2")
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected remaining turn.  Got: %v, Want: %v", order, expected)
+	}
+}
+
+func (t *ManagerSuite) WeightedPriority() {
+	m := NewManager(NewUniqueIDGenerator())
+	m.SetPriorityPolicy(WeightedPriority(1, 1, 2))
+
+	var order []string
+	for i := 0; i < 2; i++ {
+		m.NewTurnWithPriority("low", async.PriorityLow, recorder(&order, "low"))
+	}
+	for i := 0; i < 4; i++ {
+		m.NewTurnWithPriority("high", async.PriorityHigh, recorder(&order, "high"))
+	}
+	m.runOneLoop()
+
+	// Low priority turns make progress even though high priority turns are always runnable.
+	expected := []string{"high", "high", "low", "high", "high", "low"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected weighted order.  Got: %v, Want: %v", order, expected)
+	}
+}
+
+func (t *ManagerSuite) UnlinkPriority() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	didRun := false
+	t1 := NewTurnWithPriority("t1", async.PriorityHigh, func() { didRun = true })
+	m.Queue(t1)
+	m.Queue(NewTurnWithPriority("t2", async.PriorityLow, func() {}))
+	m.Unlink(t1)
+	if n := m.length(); n != 1 {
+		t.Fatalf("Expected one turn.  Got: %v, Want: %v", n, 1)
+	}
+	m.runOneLoop()
+	if didRun {
+		t.Fatalf("Expected turn to NOT have executed.  Got: %v, Want: %v", didRun, false)
+	}
+}
+
+// newTestSource creates an I/O source on m whose completions are pushed directly by the test.
+func newTestSource(m *Manager) *turnSource {
+	src := &turnSource{
+		manager: m,
+		name:    "testSource",
+		label:   "testSource",
+		list:    Empty,
+	}
+	src.event = m.registerSource(src)
+	return src
+}
+
+// push completes an I/O computation on src that runs f.
+func (src *turnSource) push(name string, f func()) {
+	src.lock.Lock()
+	src.list = src.list.Append(NewTurn(name, f))
+	src.lock.Unlock()
+	src.event.Signal()
+}
+
+func (t *ManagerSuite) LoopBudgetMaxTurns() {
+	m := NewManager(NewUniqueIDGenerator())
+	m.SetLoopBudget(LoopBudget{MaxTurns: 2})
+
+	var order []string
+	for _, name := range []string{"t1", "t2", "t3"} {
+		m.NewTurn(name, recorder(&order, name))
+	}
+	m.runOneLoop()
+	if expected := []string{"t1", "t2"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected limited turns.  Got: %v, Want: %v", order, expected)
+	}
+	m.runOneLoop()
+	if expected := []string{"t1", "t2", "t3"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected remaining turns.  Got: %v, Want: %v", order, expected)
+	}
+
+	stats := m.Stats()
+	if stats.Loops != 2 || stats.Turns != 3 || stats.TurnLimited != 1 {
+		t.Errorf("Expected stats.  Got: %+v, Want: 2 loops, 3 turns, 1 limited", stats)
+	}
+}
+
+func (t *ManagerSuite) LoopBudgetMaxIOTurns() {
+	m := NewManager(NewUniqueIDGenerator())
+	m.SetLoopBudget(LoopBudget{MaxIOTurns: 1})
+	src := newTestSource(m)
+	defer src.Close()
+
+	var order []string
+	for _, name := range []string{"io1", "io2", "io3"} {
+		src.push(name, recorder(&order, name))
+	}
+	m.runOneLoop()
+	if expected := []string{"io1"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected limited I/O.  Got: %v, Want: %v", order, expected)
+	}
+	if m.isIdle() {
+		t.Errorf("Expected backlog.  Got: %v, Want: %v", m.backlog, "non-empty")
+	}
+
+	// Local turns are not delayed by the I/O backlog.
+	m.NewTurn("local", recorder(&order, "local"))
+	m.runOneLoop()
+	m.runOneLoop()
+	if expected := []string{"io1", "local", "io2", "io3"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected interleaving.  Got: %v, Want: %v", order, expected)
+	}
+	if !m.isIdle() {
+		t.Errorf("Expected empty manager.  Got: %v, Want: %v", m, "empty")
+	}
+
+	stats := m.Stats()
+	if stats.IOTurns != 3 || stats.IOLimited != 2 || stats.MaxIOBacklog != 3 {
+		t.Errorf("Expected stats.  Got: %+v, Want: 3 I/O turns, 2 limited, backlog 3", stats)
+	}
+}
+
+func (t *ManagerSuite) LoopBudgetMaxDuration() {
+	m := NewManager(NewUniqueIDGenerator())
+	m.SetLoopBudget(LoopBudget{MaxDuration: time.Millisecond})
+
+	count := 0
+	for i := 0; i < 3; i++ {
+		m.NewTurn("slow", func() {
+			count++
+			time.Sleep(2 * time.Millisecond)
+		})
+	}
+	m.runOneLoop()
+	if count != 1 {
+		t.Errorf("Expected one turn.  Got: %v, Want: %v", count, 1)
+	}
+	if stats := m.Stats(); stats.TurnLimited != 1 || stats.MaxLoopDuration < time.Millisecond {
+		t.Errorf("Expected limited loop.  Got: %+v, Want: 1 limited", stats)
+	}
+}
+
+func (t *ManagerSuite) ReusesTurns() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	first := m.allocTurn("When", m.NewID(), async.PriorityNormal, func() {})
+	m.Queue(first)
+	m.runOneLoop()
+
+	// A turn allocated after the first has run reuses it.
+	second := m.allocTurn("When", m.NewID(), async.PriorityHigh, func() {})
+	if second != first {
+		t.Errorf("Expected turn to be reused.  Got: %p, Want: %p", second, first)
+	}
+	if name := second.Name(); name != "When2" {
+		t.Errorf("Expected lazily formatted name.  Got: %v, Want: %v", name, "When2")
+	}
+	if p := second.Priority(); p != async.PriorityHigh {
+		t.Errorf("Expected priority.  Got: %v, Want: %v", p, async.PriorityHigh)
+	}
+
+	// Turns created with NewTurn are owned by the caller and are never reused.
+	owned := NewTurn("owned", func() {})
+	m.Queue(owned)
+	m.runOneLoop()
+	m.Queue(second)
+	m.runOneLoop()
+	if third := m.allocTurn("When", m.NewID(), async.PriorityNormal, func() {}); third == owned {
+		t.Errorf("Expected caller owned turn NOT to be reused.  Got: %p, Want: %p", third, second)
+	}
+}
+
+func (t *ManagerSuite) Dequeue() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	var order []string
+	t1 := NewTurn("t1", recorder(&order, "t1"))
+	t2 := NewTurn("t2", recorder(&order, "t2"))
+	t3 := NewTurn("t3", recorder(&order, "t3"))
+	m.Queue(t1)
+	m.Queue(t2)
+	m.Queue(t3)
+
+	// Remove the turn from the middle of the queue.
+	if !m.Dequeue(t2) {
+		t.Errorf("Expected dequeue to succeed.  Got: false, Want: true")
+	}
+	if m.Dequeue(t2) {
+		t.Errorf("Expected second dequeue to fail.  Got: true, Want: false")
+	}
+
+	// Turns queued on another manager or already run cannot be dequeued.
+	other := NewManager(NewUniqueIDGenerator())
+	if other.Dequeue(t1) {
+		t.Errorf("Expected dequeue from another manager to fail.  Got: true, Want: false")
+	}
+	m.runOneLoop()
+	if m.Dequeue(t1) {
+		t.Errorf("Expected dequeue of a run turn to fail.  Got: true, Want: false")
+	}
+	if expected := []string{"t1", "t3"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected dequeued turn NOT to run.  Got: %v, Want: %v", order, expected)
+	}
+}
+
+// BenchmarkDequeue measures removing turns from the middle of a long queue.
+func BenchmarkDequeue(b *testing.B) {
+	m := NewManager(NewUniqueIDGenerator())
+	turns := make([]*Turn, 100000)
+	for i := range turns {
+		turns[i] = NewTurn("t", func() {})
+		m.Queue(turns[i])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := turns[(i*7919)%len(turns)]
+		m.Dequeue(t)
+		m.Queue(t)
+	}
+}
+
+func (t *ManagerSuite) RandomPreservesCauseOrder() {
+	orders := make(map[string]bool)
+	for seed := int64(1); seed <= 20; seed++ {
+		m := NewManager(NewUniqueIDGenerator())
+		m.Randomize(seed)
+
+		// Each parent queues two children which MUST run in order.
+		var order []string
+		for _, parent := range []string{"a", "b", "c"} {
+			parent := parent
+			m.NewTurn(parent, func() {
+				m.NewTurn(parent+"1", recorder(&order, parent+"1"))
+				m.NewTurn(parent+"2", recorder(&order, parent+"2"))
+			})
+		}
+		for m.RunOneTurn() {
+		}
+
+		position := make(map[string]int)
+		for i, name := range order {
+			position[name] = i
+		}
+		for _, parent := range []string{"a", "b", "c"} {
+			if position[parent+"1"] > position[parent+"2"] {
+				t.Errorf("Expected children in order.  Got: %v, Want: %s1 before %s2", order, parent,
+					parent)
+			}
+		}
+		orders[fmt.Sprint(order)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("Expected different seeds to reorder turns.  Got: %v, Want: more than 1", orders)
+	}
+}
+
+func (t *ManagerSuite) Timers() {
+	m := NewManager(NewUniqueIDGenerator())
+	clock := NewFakeClock(time.Unix(0, 0), false)
+	m.SetClock(clock)
+
+	var order []string
+	m.After(2*time.Second, recorder(&order, "t2"))
+	m.After(time.Second, recorder(&order, "t1a"))
+	m.After(time.Second, recorder(&order, "t1b"))
+	m.runOneLoop()
+	if len(order) != 0 {
+		t.Errorf("Expected no timers to fire.  Got: %v, Want: []", order)
+	}
+
+	clock.Advance(time.Second)
+	m.runOneLoop()
+	if expected := []string{"t1a", "t1b"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected expired timers in order.  Got: %v, Want: %v", order, expected)
+	}
+
+	clock.Advance(time.Hour)
+	m.runOneLoop()
+	if expected := []string{"t1a", "t1b", "t2"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected all timers.  Got: %v, Want: %v", order, expected)
+	}
+}
+
+func (t *ManagerSuite) RealTimer() {
+	m := NewManager(NewUniqueIDGenerator())
+
+	s := newTurnResolver(m)
+	r := async.R{ResultT: async.NewResultT(s)}
+	start := time.Now()
+	m.After(10*time.Millisecond, func() {
+		s.Complete(nil)
+	})
+	if err := m.RunUntil(r); err != nil {
+		t.Errorf("Expected RunUntil to succeed.  Got: %v, Want: nil", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected timer to wait.  Got: %v, Want: >= 10ms", elapsed)
+	}
+}
+
+func (t *ManagerSuite) Metrics() {
+	m := NewManager(NewUniqueIDGenerator())
+	metrics := m.EnableMetrics()
+	src := newTestSource(m)
+	defer src.Close()
+
+	m.NewTurn("t1", func() {})
+	m.NewTurn("t2", func() {})
+	src.push("io1", func() {})
+	src.push("io2", func() {})
+	m.runOneLoop()
+
+	if got := metrics.TurnsRun.Get(); got != 4 {
+		t.Errorf("Expected turns run.  Got: %v, Want: %v", got, 4)
+	}
+	if got := metrics.IOTurnsBySource()["testSource"]; got != 2 {
+		t.Errorf("Expected I/O turns by source.  Got: %v, Want: %v", got, 2)
+	}
+	if got := metrics.TurnDuration.Snapshot().Count; got != 4 {
+		t.Errorf("Expected turn durations.  Got: %v, Want: %v", got, 4)
+	}
+	if got := metrics.QueueDepth.Get(); got != 0 {
+		t.Errorf("Expected empty queue.  Got: %v, Want: %v", got, 0)
+	}
+}
+
+// Close verifies that closers run once, in reverse order of registration.
+// MetricsSourcesBounded verifies that I/O is counted by source label and that closed sources are
+// forgotten.
+func (t *ManagerSuite) MetricsSourcesBounded() {
+	m := NewManager(NewUniqueIDGenerator())
+	metrics := m.EnableMetrics()
+	for i := 0; i < 1000; i++ {
+		src1, src2 := newTestSource(m), newTestSource(m)
+		src1.push("io1", func() {})
+		src2.push("io2", func() {})
+		m.runOneLoop()
+		if got := metrics.IOTurnsBySource(); len(got) != 1 || got["testSource"] != 2 {
+			t.Fatalf("Expected I/O counted by label.  Got: %v, Want: map[testSource:2]", got)
+		}
+		src1.Close()
+		src2.Close()
+	}
+	m.runOneLoop()
+
+	if got := metrics.IOTurnsBySource(); len(got) != 0 {
+		t.Errorf("Expected closed sources to be dropped.  Got: %v, Want: map[]", got)
+	}
+	if got := metrics.IOTurns.Get(); got != 2000 {
+		t.Errorf("Expected all I/O turns.  Got: %v, Want: %v", got, 2000)
+	}
+}
+
+// InspectSources verifies that sources are only reported if tracked and that Inspect leaves the
+// set of tracked sources unchanged.
+func (t *ManagerSuite) InspectSources() {
+	untracked := NewManager(NewUniqueIDGenerator())
+	src := newTestSource(untracked)
+	defer src.Close()
+	if got := untracked.Inspect().Sources; got != nil {
+		t.Errorf("Expected no sources.  Got: %v, Want: nil", got)
+	}
+
+	m := NewManager(NewUniqueIDGenerator())
+	m.TrackSources()
+	open, closed := newTestSource(m), newTestSource(m)
+	defer open.Close()
+	closed.Close()
+	if got, want := m.Inspect().Sources, []string{"testSource"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the open source.  Got: %v, Want: %v", got, want)
+	}
+	if got := len(m.registered); got != 2 {
+		t.Errorf("Expected Inspect not to prune.  Got: %v, Want: %v", got, 2)
+	}
+}
+
+func (t *ManagerSuite) Close() {
+	m := NewManager(NewUniqueIDGenerator())
+	var order []int
+	m.OnClose(func() { order = append(order, 1) })
+	m.OnClose(func() { order = append(order, 2) })
+	m.Close()
+	m.Close()
+
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("Expected closers in reverse order.  Got: %v, Want: %v", order, []int{2, 1})
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the runtime metrics maintained by a Manager.  Metrics are updated on the
+// manager's thread but may be read from any thread (e.g. by an exporter serving HTTP).
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/base"
+)
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	value int64
+}
+
+// Add adds n to the counter.
+// THREADING: This method is multi-thread safe.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Get returns the current count.
+// THREADING: This method is multi-thread safe.
+func (c *Counter) Get() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	value int64
+}
+
+// Set sets the gauge's value.
+// THREADING: This method is multi-thread safe.
+func (g *Gauge) Set(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+// Get returns the gauge's value.
+// THREADING: This method is multi-thread safe.
+func (g *Gauge) Get() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// DefaultDurationBounds are the upper bounds of the buckets of duration histograms.
+var DefaultDurationBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts durations in buckets.
+type Histogram struct {
+	// bounds are the inclusive upper bounds of each bucket but the last, in increasing order.
+	bounds []time.Duration
+
+	// counts are the number of observations in each bucket.  The last bucket has no upper bound.
+	counts []int64
+
+	// count is the total number of observations.
+	count int64
+
+	// sum is the total of all observations.
+	sum int64
+}
+
+// HistogramSnapshot is a copy of a Histogram at a point in time.
+type HistogramSnapshot struct {
+	// Bounds are the inclusive upper bounds of each bucket but the last.
+	Bounds []time.Duration
+
+	// Counts are the number of observations in each bucket (NOT cumulative).  The last bucket has
+	// no upper bound.
+	Counts []int64
+
+	// Count is the total number of observations.
+	Count int64
+
+	// Sum is the total of all observations.
+	Sum time.Duration
+}
+
+// NewHistogram creates an empty histogram with the given bucket bounds.
+func NewHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe adds an observation.
+// THREADING: This method is multi-thread safe.
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// Snapshot returns a copy of the histogram.
+// THREADING: This method is multi-thread safe.  Concurrent observations may be partially included.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]int64, len(h.counts)),
+		Count:  atomic.LoadInt64(&h.count),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadInt64(&h.counts[i])
+	}
+	return s
+}
+
+// Metrics are the runtime metrics of a Manager.
+type Metrics struct {
+	// TurnsRun is the number of turns run.
+	TurnsRun Counter
+
+	// QueueDepth is the number of turns waiting to run (including I/O in the backlog) at the end of
+	// the last loop iteration.
+	QueueDepth Gauge
+
+	// IOTurns is the number of I/O completions ingested.
+	IOTurns Counter
+
+	// SlowTurns is the number of turns reported by the manager's watchdog (see StartWatchdog).
+	SlowTurns Counter
+
+	// TurnDuration is the distribution of the time taken to run each turn.
+	TurnDuration *Histogram
+
+	// WaitDuration is the distribution of the time the manager spent blocked waiting for I/O or
+	// timers.
+	WaitDuration *Histogram
+
+	// ResultsCreated is the number of results created.
+	ResultsCreated Counter
+
+	// ResultsResolved is the number of results resolved.
+	ResultsResolved Counter
+
+	// ResultsForwarded is the number of results forwarded to other results.
+	ResultsForwarded Counter
+
+	// lock protects ioBySource.
+	lock sync.Mutex
+
+	// ioBySource is the number of I/O completions ingested from the open sources with each label.
+	ioBySource map[string]*sourceCounter
+}
+
+// sourceCounter counts the I/O completions ingested from the sources with a label.
+type sourceCounter struct {
+	Counter
+
+	// open is the number of open sources with the label.
+	open int
+}
+
+// newMetrics creates a new set of metrics.
+func newMetrics() *Metrics {
+	return &Metrics{
+		TurnDuration: NewHistogram(DefaultDurationBounds),
+		WaitDuration: NewHistogram(DefaultDurationBounds),
+		ioBySource:   make(map[string]*sourceCounter),
+	}
+}
+
+// IOTurnsBySource returns the number of I/O completions ingested from sources by label (see
+// NewNamedTurnSource).  Only labels with open sources are included: the count for a label restarts
+// from zero once all its sources have been closed.
+// THREADING: This method is multi-thread safe.
+func (m *Metrics) IOTurnsBySource() map[string]int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make(map[string]int64, len(m.ioBySource))
+	for name, c := range m.ioBySource {
+		result[name] = c.Get()
+	}
+	return result
+}
+
+// openSource starts counting the I/O completions ingested from a new source with the given label.
+// THREADING: This method is multi-thread safe.
+func (m *Metrics) openSource(label string) {
+	m.lock.Lock()
+	c, ok := m.ioBySource[label]
+	if !ok {
+		c = &sourceCounter{}
+		m.ioBySource[label] = c
+	}
+	c.open++
+	m.lock.Unlock()
+}
+
+// closeSource stops counting the I/O completions ingested from a closed source with the given
+// label.  The label's count is dropped once all its sources have been closed.
+// THREADING: This method is multi-thread safe.
+func (m *Metrics) closeSource(label string) {
+	m.lock.Lock()
+	if c, ok := m.ioBySource[label]; ok {
+		if c.open--; c.open <= 0 {
+			delete(m.ioBySource, label)
+		}
+	}
+	m.lock.Unlock()
+}
+
+// addIO counts n I/O completions ingested from a source with the given label.  Completions from
+// sources opened before metrics were enabled (or ingested after their source closed) are only
+// counted in IOTurns.
+func (m *Metrics) addIO(label string, n int) {
+	m.lock.Lock()
+	c := m.ioBySource[label]
+	m.lock.Unlock()
+
+	if c != nil {
+		c.Add(int64(n))
+	}
+	m.IOTurns.Add(int64(n))
+}
+
+// EnableMetrics starts collecting runtime metrics for the manager and returns them.  Metrics are
+// not collected by default because timing every turn has a cost.
+// EnableMetrics MUST be called before the manager starts running turns.
+func (m *Manager) EnableMetrics() *Metrics {
+	if m.metrics == nil {
+		m.metrics = newMetrics()
+	}
+	return m.metrics
+}
+
+// Metrics returns the manager's metrics, or nil if they are not enabled.
+func (m *Manager) Metrics() *Metrics {
+	return m.metrics
+}
+
+// wait blocks on the manager's sources (see EventSet.Wait), recording the time spent blocked.
+func (m *Manager) wait() *base.Event {
+	if m.metrics == nil {
+		return m.waitSources()
+	}
+	start := time.Now()
+	e := m.waitSources()
+	m.metrics.WaitDuration.Observe(time.Since(start))
+	return e
+}
+
+// waitTimeout blocks on the manager's sources for at most d (see EventSet.WaitTimeout), recording
+// the time spent blocked.
+func (m *Manager) waitTimeout(d time.Duration) *base.Event {
+	if m.metrics == nil {
+		return m.sources.WaitTimeout(d)
+	}
+	start := time.Now()
+	e := m.sources.WaitTimeout(d)
+	m.metrics.WaitDuration.Observe(time.Since(start))
+	return e
+}
+
+// sleep blocks for d, recording the time spent blocked.
+func (m *Manager) sleep(d time.Duration) {
+	time.Sleep(d)
+	if m.metrics != nil {
+		m.metrics.WaitDuration.Observe(d)
+	}
+}
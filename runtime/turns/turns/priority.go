@@ -0,0 +1,76 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+import (
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/async"
+)
+
+// PriorityPolicy determines how a Manager chooses between runnable turns of different priorities.
+// Turns of the same priority are always run in FIFO order.
+type PriorityPolicy struct {
+	// weights is the number of turns of each priority run per round.  nil means strict priority.
+	weights []int
+}
+
+// StrictPriority returns a policy that always runs the highest priority runnable turn.  Lower
+// priority turns only run when no higher priority turn is runnable and so may starve.
+func StrictPriority() PriorityPolicy {
+	return PriorityPolicy{}
+}
+
+// WeightedPriority returns a policy that runs turns in rounds.  Each round runs up to the given
+// number of turns of each priority, highest priority first, so every priority makes progress.
+// REQUIRES: all weights are positive.
+func WeightedPriority(low, normal, high int) PriorityPolicy {
+	weights := []int{low, normal, high}
+	for _, w := range weights {
+		assert.True(w > 0, "Priority weights must be positive: %v", weights)
+	}
+	return PriorityPolicy{weights: weights}
+}
+
+// IsStrict returns true if the policy is strict priority.
+func (p PriorityPolicy) IsStrict() bool {
+	return p.weights == nil
+}
+
+// choose returns the priority of the queue from which the next turn should be run given the
+// length of each queue.  credits holds the turns remaining in the current weighted round and is
+// updated.  Returns false if all queues are empty.
+func (p PriorityPolicy) choose(lengths, credits *[async.NumPriorities]int) (async.Priority, bool) {
+	if p.IsStrict() {
+		for i := async.NumPriorities - 1; i >= 0; i-- {
+			if lengths[i] > 0 {
+				return async.Priority(i), true
+			}
+		}
+		return 0, false
+	}
+
+	// Try the current round.  If it has nothing left to run then start a new round.
+	for round := 0; round < 2; round++ {
+		for i := async.NumPriorities - 1; i >= 0; i-- {
+			if lengths[i] > 0 && credits[i] > 0 {
+				credits[i]--
+				return async.Priority(i), true
+			}
+		}
+		copy(credits[:], p.weights)
+	}
+	return 0, false
+}
@@ -0,0 +1,122 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the runtime/pprof labels applied while running turns.  Without labels a CPU
+// profile attributes all of an actor's work to the manager's loop and reflect.Value.Call.  With
+// labels enabled each sample taken while a turn runs carries:
+//
+//   drydock.actor      the name given to EnableProfileLabels
+//   drydock.turn       the turn's label (its name without the unique id, e.g. "When")
+//   drydock.operation  the name of the current span (see StartSpan), if any
+//
+// so that a profile can be sliced by logical work (e.g. go tool pprof -tagfocus=drydock.operation=X).
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+const (
+	// ProfileLabelActor is the profile label naming the actor.
+	ProfileLabelActor = "drydock.actor"
+
+	// ProfileLabelTurn is the profile label naming the running turn.
+	ProfileLabelTurn = "drydock.turn"
+
+	// ProfileLabelOperation is the profile label naming the current span.
+	ProfileLabelOperation = "drydock.operation"
+)
+
+// maxProfileLabelSets bounds the number of distinct label sets cached by a manager.  Label sets
+// beyond the bound are built each time they are needed.
+const maxProfileLabelSets = 1024
+
+// profileKey identifies a set of profile labels.
+type profileKey struct {
+	turn      string
+	operation string
+}
+
+// profileLabels are the profile labels of a manager.
+type profileLabels struct {
+	// base carries the actor label only.  It is applied between turns.
+	base context.Context
+
+	// current is the label context of the running turn (or base).
+	current context.Context
+
+	// cache holds the label context of each label set seen so far.
+	cache map[profileKey]context.Context
+}
+
+// EnableProfileLabels applies runtime/pprof labels (see ProfileLabelActor, ProfileLabelTurn and
+// ProfileLabelOperation) to the manager's goroutine while each turn runs.  actor names the manager
+// in the labels.  Labels are not applied by default because setting them has a (small) cost for
+// every turn.
+// EnableProfileLabels MUST be called on the goroutine that runs the manager's turns.
+func (m *Manager) EnableProfileLabels(actor string) {
+	base := pprof.WithLabels(context.Background(), pprof.Labels(ProfileLabelActor, actor))
+	m.profile = &profileLabels{
+		base:    base,
+		current: base,
+		cache:   make(map[profileKey]context.Context),
+	}
+	pprof.SetGoroutineLabels(base)
+}
+
+// ProfileContext returns a context carrying the profile labels of the running turn, or nil if
+// profile labels are not enabled.  It can be passed to pprof.Do (or pprof.SetGoroutineLabels) to
+// attribute work done on other goroutines on behalf of the turn.
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) ProfileContext() context.Context {
+	if m.profile == nil {
+		return nil
+	}
+	return m.profile.current
+}
+
+// labelTurn applies the labels of turn t to the calling goroutine and returns the previously
+// applied label context which MUST be restored (by restoreLabels) when t returns.
+func (m *Manager) labelTurn(t *Turn) context.Context {
+	p := m.profile
+	key := profileKey{turn: t.label}
+	if t.span != nil {
+		key.operation = t.span.name
+	}
+	ctx, ok := p.cache[key]
+	if !ok {
+		labels := []string{ProfileLabelTurn, key.turn}
+		if key.operation != "" {
+			labels = append(labels, ProfileLabelOperation, key.operation)
+		}
+		ctx = pprof.WithLabels(p.base, pprof.Labels(labels...))
+		if len(p.cache) < maxProfileLabelSets {
+			p.cache[key] = ctx
+		}
+	}
+
+	previous := p.current
+	p.current = ctx
+	pprof.SetGoroutineLabels(ctx)
+	return previous
+}
+
+// restoreLabels reapplies the label context that was applied before the running turn.
+func (m *Manager) restoreLabels(previous context.Context) {
+	m.profile.current = previous
+	pprof.SetGoroutineLabels(previous)
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"runtime/pprof"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// ProfileSuite is the test suite for profile labels.
+type ProfileSuite struct {
+	test.Suite
+}
+
+// TestProfileSuite runs the test suite for profile labels.
+func TestProfileSuite(t *testing.T) {
+	test.RunSuite(t, new(ProfileSuite))
+}
+
+// Labels verifies that each turn runs with the actor, turn and operation labels.
+func (t *ProfileSuite) Labels() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+	if ctx := m.ProfileContext(); ctx != nil {
+		t.Errorf("Expected no labels by default.  Got: %v, Want: nil", ctx)
+	}
+	m.EnableProfileLabels("server")
+
+	type labels struct {
+		actor, turn, operation string
+	}
+	label := func(key string) string {
+		v, _ := pprof.Label(m.ProfileContext(), key)
+		return v
+	}
+	current := func() labels {
+		return labels{
+			actor:     label(turns.ProfileLabelActor),
+			turn:      label(turns.ProfileLabelTurn),
+			operation: label(turns.ProfileLabelOperation),
+		}
+	}
+
+	var plain, spanned labels
+	m.NewTurn("Plain", func() {
+		plain = current()
+	})
+	m.NewTurn("Spanned", func() {
+		span := m.StartSpan("request")
+		async.New(func() async.R {
+			spanned = current()
+			span.End()
+			return async.Done()
+		})
+	})
+	for m.RunTurns(0) > 0 {
+	}
+
+	if want := (labels{"server", "Plain", ""}); plain != want {
+		t.Errorf("Expected plain labels.  Got: %+v, Want: %+v", plain, want)
+	}
+	if want := (labels{"server", "New", "request"}); spanned != want {
+		t.Errorf("Expected span labels.  Got: %+v, Want: %+v", spanned, want)
+	}
+	if got := current(); got != (labels{actor: "server"}) {
+		t.Errorf("Expected actor labels between turns.  Got: %+v, Want: %+v", got,
+			labels{actor: "server"})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the randomized scheduler used to fuzz turn interleavings in tests.  The
+// randomized scheduler only reorders turns where the programming model makes no ordering promise:
+//
+//   - Turns queued by the same turn (e.g. the continuations of a result, or a series of New calls)
+//     always run in the order they were queued.
+//   - Turns of higher priority still run before turns of lower priority.
+//   - I/O completions may arrive in any order and may be delayed by any number of loop iterations.
+
+import "math/rand"
+
+// Randomize puts the manager into a test mode in which the order of ready turns and the arrival
+// of I/O completions is chosen pseudo-randomly from seed.  Given the same seed and the same order
+// of I/O arrival (see Record and Replay) the same interleaving is produced.
+func (m *Manager) Randomize(seed int64) {
+	m.random = rand.New(rand.NewSource(seed))
+}
+
+// newCause returns a new cause for turns that are not ordered with respect to any other turn.
+func (m *Manager) newCause() uint64 {
+	m.causes++
+	return m.causes
+}
+
+// pickRandom chooses a random turn from list that is the oldest turn queued by its cause.
+// REQUIRES: list is not empty.
+func (m *Manager) pickRandom(list *Turn) *Turn {
+	seen := make(map[uint64]bool)
+	var candidates []*Turn
+	t := list.Peek()
+	for {
+		if !seen[t.cause] {
+			seen[t.cause] = true
+			candidates = append(candidates, t)
+		}
+		if t == list {
+			break
+		}
+		t = t.next
+	}
+	return candidates[m.random.Intn(len(candidates))]
+}
+
+// randomIOCount returns the number of I/O completions to move from a backlog of n to the main
+// queue.  At least one completion is moved if there is no other work to do.
+func (m *Manager) randomIOCount(n int) int {
+	if n == 0 {
+		return 0
+	}
+	if m.length() == 0 {
+		return 1 + m.random.Intn(n)
+	}
+	return m.random.Intn(n + 1)
+}
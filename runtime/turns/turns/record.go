@@ -0,0 +1,215 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the recording and replay of an actor's execution.  All nondeterminism in an
+// actor enters through the completion of I/O computations on turn sources and the firing of timers
+// (whose deadlines depend on the clock), so recording which I/O completions were ingested and which
+// timers fired in which iteration of the turn loop (and the outcomes of the I/O) is enough to
+// reproduce the exact turn interleaving when the same code is run again.
+//
+// During replay I/O functions are never executed.  Instead each I/O computation completes in the
+// same loop iteration, in the same order and with the same outcome as it did when recorded.
+// Likewise each timer fires in the loop iteration in which it fired when recorded, whatever the
+// manager's clock says, so the turns of a timer that reads the clock see a different time.  Only
+// the error returned by an I/O function is recorded; I/O functions that pass other results back
+// through captured variables must record those results themselves.  Errors are replayed as new
+// errors with the same message.  Replay is only exact if the loop budget does not include a
+// MaxDuration (which depends on the wall clock).
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	log "github.com/golang/glog"
+)
+
+// ErrReplayDiverged is returned by RunUntil during replay if the execution no longer matches the
+// recording (e.g. because the code has changed).
+var ErrReplayDiverged = errors.New("replay diverged from recording")
+
+// ErrReplayExhausted is returned by RunUntil during replay if the manager runs out of work before
+// main is resolved and there are no more recorded I/O completions or timers to replay.
+var ErrReplayExhausted = errors.New("replay recording exhausted")
+
+// IORecord is a single entry in a recording.  It records the completion of one I/O computation or
+// the firing of one timer.
+type IORecord struct {
+	// Loop is the turn loop iteration that ingested the completion or fired the timer.
+	Loop int64 `json:"loop"`
+
+	// IO identifies the I/O computation by the order in which it was started on the manager (or is
+	// zero if the record is for a timer).
+	IO int64 `json:"io,omitempty"`
+
+	// Timer identifies the timer by the order in which it was created on the manager (or is zero if
+	// the record is for an I/O computation).
+	Timer uint64 `json:"timer,omitempty"`
+
+	// Err is the message of the error returned by the I/O function, if any.
+	Err string `json:"err,omitempty"`
+}
+
+// ioCompletion is the outcome of an I/O computation as seen by a recording source.
+type ioCompletion struct {
+	// seq identifies the I/O computation.
+	seq int64
+
+	// err is the error returned by the I/O function.
+	err error
+}
+
+// pendingIO is an I/O computation that has been started during replay but not yet completed.
+type pendingIO struct {
+	// turn resolves the computation's result when run.
+	turn *Turn
+
+	// err is set to the recorded outcome before turn runs.
+	err *error
+}
+
+// replayer substitutes recorded outcomes for the execution of I/O functions.
+type replayer struct {
+	// records is the recording being replayed.
+	records []IORecord
+
+	// next is the index of the next record to replay.
+	next int
+
+	// pending are the I/O computations started but not yet completed, by sequence number.
+	pending map[int64]pendingIO
+
+	// err is set if the execution diverges from the recording.
+	err error
+}
+
+// Record starts recording the manager's I/O completions and timer firings to w as a series of JSON
+// encoded IORecords, one per line.  Record MUST be called before the manager starts running turns.
+func (m *Manager) Record(w io.Writer) {
+	m.recorder = json.NewEncoder(w)
+}
+
+// Replay reads a recording produced by Record and puts the manager into replay mode.  From then on
+// I/O computations started on the manager are completed from the recording rather than being
+// executed, and timers fire when the recording says rather than when the clock says.  Replay MUST
+// be called before the manager starts running turns.
+func (m *Manager) Replay(r io.Reader) error {
+	var records []IORecord
+	decoder := json.NewDecoder(r)
+	for {
+		var record IORecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	m.replay = &replayer{
+		records: records,
+		pending: make(map[int64]pendingIO),
+	}
+	return nil
+}
+
+// nextIO returns the sequence number of a new I/O computation.
+func (m *Manager) nextIO() int64 {
+	m.ioSeq++
+	return m.ioSeq
+}
+
+// record writes the completions ingested by the current loop iteration to the recording.
+func (m *Manager) record(completions []ioCompletion) {
+	for _, c := range completions {
+		record := IORecord{
+			Loop: m.stats.Loops,
+			IO:   c.seq,
+		}
+		if c.err != nil {
+			record.Err = c.err.Error()
+		}
+		if err := m.recorder.Encode(&record); err != nil {
+			log.Errorf("Recording stopped: %v", err)
+			m.recorder = nil
+			return
+		}
+	}
+}
+
+// recordTimer writes the firing of the timer seq in the current loop iteration to the recording.
+func (m *Manager) recordTimer(seq uint64) {
+	record := IORecord{
+		Loop:  m.stats.Loops,
+		Timer: seq,
+	}
+	if err := m.recorder.Encode(&record); err != nil {
+		log.Errorf("Recording stopped: %v", err)
+		m.recorder = nil
+	}
+}
+
+// expect registers an I/O computation started during replay.  err is set to the recorded outcome
+// before turn is queued.
+func (r *replayer) expect(seq int64, turn *Turn, err *error) {
+	r.pending[seq] = pendingIO{
+		turn: turn,
+		err:  err,
+	}
+}
+
+// ingest moves the I/O completions recorded for the current loop iteration onto the backlog and
+// queues the timers recorded as firing in it.  Returns the number of completions ingested.
+func (r *replayer) ingest(m *Manager) int {
+	count := 0
+	for ; r.next < len(r.records); r.next++ {
+		record := r.records[r.next]
+		if record.Loop > m.stats.Loops {
+			break
+		}
+
+		if record.Timer != 0 {
+			if (record.Loop < m.stats.Loops) || !m.fireTimer(record.Timer) {
+				log.Errorf("Replay diverged at loop %d: timer %d was not waiting", m.stats.Loops,
+					record.Timer)
+				r.err = ErrReplayDiverged
+				break
+			}
+			continue
+		}
+
+		pending, ok := r.pending[record.IO]
+		if !ok || (record.Loop < m.stats.Loops) {
+			log.Errorf("Replay diverged at loop %d: I/O %d was not started", m.stats.Loops, record.IO)
+			r.err = ErrReplayDiverged
+			break
+		}
+		delete(r.pending, record.IO)
+
+		if record.Err != "" {
+			*pending.err = errors.New(record.Err)
+		}
+		m.backlog = m.backlog.Append(pending.turn)
+		count++
+	}
+	return count
+}
+
+// exhausted returns true if every recorded I/O completion and timer has been replayed.
+func (r *replayer) exhausted() bool {
+	return r.next == len(r.records)
+}
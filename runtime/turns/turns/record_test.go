@@ -0,0 +1,204 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// RecordSuite is the test suite for recording and replay.
+type RecordSuite struct {
+	test.Suite
+}
+
+// TestRecordSuite runs the test suite for recording and replay.
+func TestRecordSuite(t *testing.T) {
+	test.RunSuite(t, new(RecordSuite))
+}
+
+// runManager runs main on a new manager configured by configure.
+func runManager(configure func(m *turns.Manager), main async.Func) error {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	configure(m)
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	r, s := async.NewR()
+	m.NewTurn("Main", func() {
+		s.Forward(main())
+	})
+	return m.RunUntil(r)
+}
+
+// racingIO starts count I/O computations that complete after random delays, each followed by a
+// chain of local turns, and records the order in which the turns ran.  io is called to execute
+// each I/O computation.
+func racingIO(count int, io func(i int) error, order *[]string) async.Func {
+	return func() async.R {
+		src := turns.NewTurnSource()
+		last := async.Done()
+		for i := 0; i < count; i++ {
+			i := i
+			r := async.When(src.New(func() error { return io(i) }), func(err error) async.R {
+				*order = append(*order, fmt.Sprintf("io%d:%v", i, err))
+				return async.New(func() async.R {
+					*order = append(*order, fmt.Sprintf("local%d", i))
+					return async.Done()
+				})
+			})
+			prev := last
+			last = async.When(prev, func() async.R { return r })
+		}
+		return async.Finally(last, src.Close)
+	}
+}
+
+// liveIO sleeps for a random time and fails one computation.
+func liveIO(i int) error {
+	time.Sleep(time.Duration(rand.Intn(5000)) * time.Microsecond)
+	if i == 3 {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+// RecordReplay verifies that a replayed execution runs turns in exactly the recorded order without
+// executing any I/O.
+func (t *RecordSuite) RecordReplay() {
+	var recording bytes.Buffer
+	var recorded []string
+	err := runManager(func(m *turns.Manager) {
+		m.Record(&recording)
+	}, racingIO(10, liveIO, &recorded))
+	if err != nil {
+		t.Fatalf("Expected recording to succeed.  Got: %v, Want: nil", err)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		var replayed []string
+		input := bytes.NewReader(recording.Bytes())
+		err = runManager(func(m *turns.Manager) {
+			if err := m.Replay(input); err != nil {
+				t.Fatalf("Expected recording to load.  Got: %v, Want: nil", err)
+			}
+		}, racingIO(10, func(i int) error {
+			t.Errorf("Expected I/O NOT to execute during replay.  Got: %d, Want: none", i)
+			return nil
+		}, &replayed))
+		if err != nil {
+			t.Errorf("Expected replay to succeed.  Got: %v, Want: nil", err)
+		}
+		if !reflect.DeepEqual(replayed, recorded) {
+			t.Errorf("Expected same interleaving.  Got: %v, Want: %v", replayed, recorded)
+		}
+	}
+}
+
+// timedIO is like racingIO but follows each I/O computation with a timer whose duration is given by
+// delay, and records the order in which the timers fired.
+func timedIO(count int, io func(i int) error, delay func(i int) time.Duration,
+	order *[]string) async.Func {
+	return func() async.R {
+		src := turns.NewTurnSource()
+		last := async.Done()
+		for i := 0; i < count; i++ {
+			i := i
+			r := async.When(src.New(func() error { return io(i) }), func(err error) async.R {
+				*order = append(*order, fmt.Sprintf("io%d:%v", i, err))
+				return async.When(async.After(delay(i)), func() {
+					*order = append(*order, fmt.Sprintf("timer%d", i))
+				})
+			})
+			prev := last
+			last = async.When(prev, func() async.R { return r })
+		}
+		return async.Finally(last, src.Close)
+	}
+}
+
+// ReplayTimers verifies that timers fire during replay when they fired in the recording rather
+// than when the clock says, even if they are waited on after the last I/O.
+func (t *RecordSuite) ReplayTimers() {
+	var recording bytes.Buffer
+	var recorded []string
+	err := runManager(func(m *turns.Manager) {
+		m.Record(&recording)
+	}, timedIO(5, liveIO, func(int) time.Duration {
+		return time.Duration(rand.Intn(5000)) * time.Microsecond
+	}, &recorded))
+	if err != nil {
+		t.Fatalf("Expected recording to succeed.  Got: %v, Want: nil", err)
+	}
+
+	// The replay's clock never moves so the timers can only fire from the recording.
+	var replayed []string
+	err = runManager(func(m *turns.Manager) {
+		m.SetClock(turns.NewFakeClock(time.Unix(0, 0), false))
+		if err := m.Replay(bytes.NewReader(recording.Bytes())); err != nil {
+			t.Fatalf("Expected recording to load.  Got: %v, Want: nil", err)
+		}
+	}, timedIO(5, func(i int) error {
+		t.Errorf("Expected I/O NOT to execute during replay.  Got: %d, Want: none", i)
+		return nil
+	}, func(int) time.Duration {
+		return time.Hour
+	}, &replayed))
+	if err != nil {
+		t.Errorf("Expected replay to succeed.  Got: %v, Want: nil", err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("Expected same interleaving.  Got: %v, Want: %v", replayed, recorded)
+	}
+}
+
+// ReplayDiverged verifies that replaying against code that starts different I/O fails.
+func (t *RecordSuite) ReplayDiverged() {
+	var recording bytes.Buffer
+	var order []string
+	if err := runManager(func(m *turns.Manager) {
+		m.Record(&recording)
+	}, racingIO(3, liveIO, &order)); err != nil {
+		t.Fatalf("Expected recording to succeed.  Got: %v, Want: nil", err)
+	}
+
+	err := runManager(func(m *turns.Manager) {
+		m.Replay(&recording)
+	}, racingIO(1, liveIO, &order))
+	if err != turns.ErrReplayDiverged {
+		t.Errorf("Expected divergence.  Got: %v, Want: %v", err, turns.ErrReplayDiverged)
+	}
+}
+
+// ReplayExhausted verifies that replay fails if the program waits on I/O that was never recorded.
+func (t *RecordSuite) ReplayExhausted() {
+	var order []string
+	err := runManager(func(m *turns.Manager) {
+		m.Replay(&bytes.Buffer{})
+	}, racingIO(1, liveIO, &order))
+	if err != turns.ErrReplayExhausted {
+		t.Errorf("Expected exhaustion.  Got: %v, Want: %v", err, turns.ErrReplayExhausted)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains logical spans.  A span annotates a logical operation (e.g. "handle request
+// 42") that may run across many turns.  Once started, a span is the current span for the rest of
+// the turn that started it and for every turn queued on its behalf: computations created with
+// async.New, continuations registered with async.When (which run in the span that was current when
+// When was called, not the one current when the result resolved), I/O completions and timers.  So
+// a span follows a chain of continuations automatically without being passed explicitly.
+//
+// Spans started while another span is current become its children.  Ended spans are written to the
+// manager's SpanExporter (if any) as OpenTelemetry (OTLP) JSON.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/async"
+)
+
+// Span is a logical operation that may run across many turns.
+type Span struct {
+	// manager is the manager on which the span was started.
+	manager *Manager
+
+	// name describes the operation.
+	name string
+
+	// traceID identifies the tree of spans to which the span belongs.
+	traceID [16]byte
+
+	// id identifies the span.
+	id [8]byte
+
+	// parent is the span that was current when the span started (or nil).
+	parent *Span
+
+	// start is when the span started.
+	start time.Time
+
+	// end is when the span ended (or zero if it has not ended).
+	end time.Time
+
+	// attributes are the key/value pairs describing the operation, in the order they were set.
+	attributes []spanAttribute
+}
+
+// spanAttribute is a key/value pair describing a span.
+type spanAttribute struct {
+	key   string
+	value interface{}
+}
+
+// StartSpan starts a new span with the given name on the current runner.  The span is a child of
+// the current span (if any) and becomes the current span.  The span MUST be ended by calling End.
+func StartSpan(name string) *Span {
+	return async.GetCurrentRunner().(*turnRunner).manager.StartSpan(name)
+}
+
+// InSpan runs f in a new span with the given name.  The span ends when the result returned by f
+// is resolved.  Unlike StartSpan, the span is only current while f runs (and for the turns queued
+// by f).
+func InSpan(name string, f async.Func) async.R {
+	span := StartSpan(name)
+	r := async.When(f(), func(err error) error {
+		if err != nil {
+			span.SetAttribute("error", err.Error())
+		}
+		span.End()
+		return err
+	})
+	if m := span.manager; m.span == span {
+		m.span = span.parent
+	}
+	return r
+}
+
+// CurrentSpan returns the current span on the current runner, or nil if there is none.
+func CurrentSpan() *Span {
+	return async.GetCurrentRunner().(*turnRunner).manager.span
+}
+
+// StartSpan starts a new span with the given name.  The span is a child of the current span (if
+// any) and becomes the current span.
+// THREADING: This method MUST only be called on the manager's thread.
+func (m *Manager) StartSpan(name string) *Span {
+	s := &Span{
+		manager: m,
+		name:    name,
+		parent:  m.span,
+		start:   time.Now(),
+	}
+	if s.parent != nil {
+		s.traceID = s.parent.traceID
+	} else {
+		randomBytes(s.traceID[:])
+	}
+	randomBytes(s.id[:])
+	m.span = s
+	return s
+}
+
+// randomBytes fills b with random bytes.
+func randomBytes(b []byte) {
+	_, err := rand.Read(b)
+	assert.True(err == nil, "Failed to generate a span ID: %v", err)
+}
+
+// Name returns the name of the span.
+func (s *Span) Name() string {
+	return s.name
+}
+
+// Parent returns the parent of the span (or nil if it is a root span).
+func (s *Span) Parent() *Span {
+	return s.parent
+}
+
+// TraceID returns the hex encoded ID of the tree of spans to which the span belongs.
+func (s *Span) TraceID() string {
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// ID returns the hex encoded ID of the span.
+func (s *Span) ID() string {
+	return hex.EncodeToString(s.id[:])
+}
+
+// String implements fmt.Stringer.
+func (s *Span) String() string {
+	return fmt.Sprintf("%s(%s)", s.name, s.ID())
+}
+
+// SetAttribute sets an attribute describing the span.  value should be a string, bool, integer or
+// floating point number.  Other values are recorded as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, spanAttribute{key, value})
+}
+
+// Attribute returns the value of an attribute (or nil if it is not set).
+func (s *Span) Attribute(key string) interface{} {
+	for _, a := range s.attributes {
+		if a.key == key {
+			return a.value
+		}
+	}
+	return nil
+}
+
+// End ends the span and exports it.  If the span is current it is replaced as the current span by
+// its parent.  Ending a span more than once has no effect.
+// THREADING: This method MUST only be called on the manager's thread.
+func (s *Span) End() {
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	if s.manager.span == s {
+		s.manager.span = s.parent
+	}
+	if s.manager.spans != nil {
+		s.manager.spans.export(s)
+	}
+}
+
+// SpanExporter writes ended spans in the OpenTelemetry protocol's JSON encoding.  Each span is
+// written as a separate line containing an ExportTraceServiceRequest (the format of the
+// OpenTelemetry collector's file exporter).
+type SpanExporter struct {
+	// lock protects w and err.
+	lock sync.Mutex
+
+	// w is the destination of the spans.
+	w io.Writer
+
+	// service is the name of the service recorded in the resource of each span.
+	service string
+
+	// err is the first error that occurred while exporting.
+	err error
+}
+
+// NewSpanExporter creates an exporter that writes spans to w.  service names the program in which
+// the spans occurred.
+func NewSpanExporter(w io.Writer, service string) *SpanExporter {
+	return &SpanExporter{
+		w:       w,
+		service: service,
+	}
+}
+
+// Err returns the first error (if any) that occurred while exporting.
+func (e *SpanExporter) Err() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.err
+}
+
+// SetSpanExporter causes the manager to export spans to e when they end.  Several managers may
+// share an exporter.
+func (m *Manager) SetSpanExporter(e *SpanExporter) {
+	m.spans = e
+}
+
+// The OTLP JSON encoding of a span.  See opentelemetry/proto/trace/v1/trace.proto.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// otlpSpanKindInternal is the OTLP kind of spans that represent internal operations.
+const otlpSpanKindInternal = 1
+
+// newOTLPValue converts an attribute value to its OTLP encoding.  64-bit integers are encoded as
+// strings as required by the protobuf JSON mapping.
+func newOTLPValue(value interface{}) otlpValue {
+	var v otlpValue
+	switch x := value.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int:
+		s := strconv.FormatInt(int64(x), 10)
+		v.IntValue = &s
+	case int32:
+		s := strconv.FormatInt(int64(x), 10)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case uint32:
+		s := strconv.FormatUint(uint64(x), 10)
+		v.IntValue = &s
+	case float32:
+		f := float64(x)
+		v.DoubleValue = &f
+	case float64:
+		v.DoubleValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return v
+}
+
+// export writes an ended span.
+// THREADING: This method is multi-thread safe.
+func (e *SpanExporter) export(s *Span) {
+	span := otlpSpan{
+		TraceID:           s.TraceID(),
+		SpanID:            s.ID(),
+		Name:              s.name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parent != nil {
+		span.ParentSpanID = s.parent.ID()
+	}
+	for _, a := range s.attributes {
+		span.Attributes = append(span.Attributes, otlpAttribute{a.key, newOTLPValue(a.value)})
+	}
+	request := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{"service.name", newOTLPValue(e.service)}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/prolang/drydock/runtime/turns"},
+				Spans: []otlpSpan{span},
+			}},
+		}},
+	}
+	b, err := json.Marshal(request)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.err != nil {
+		return
+	}
+	if err != nil {
+		e.err = err
+		return
+	}
+	_, e.err = e.w.Write(append(b, '\n'))
+}
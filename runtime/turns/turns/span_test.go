@@ -0,0 +1,149 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// SpanSuite is the test suite for logical spans.
+type SpanSuite struct {
+	test.Suite
+}
+
+// TestSpanSuite runs the test suite for logical spans.
+func TestSpanSuite(t *testing.T) {
+	test.RunSuite(t, new(SpanSuite))
+}
+
+// exportedSpan is the subset of an OTLP span verified by the tests.
+type exportedSpan struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Attributes   []struct {
+		Key   string
+		Value map[string]interface{}
+	}
+}
+
+// readSpans parses the spans written by a SpanExporter.
+func readSpans(buf *bytes.Buffer) (map[string]exportedSpan, error) {
+	spans := make(map[string]exportedSpan)
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan
+				}
+			}
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, err
+		}
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s
+				}
+			}
+		}
+	}
+	return spans, scanner.Err()
+}
+
+// FollowsWhen verifies that a span is current in the continuations registered while it was
+// current, and that spans started there are its children.
+func (t *SpanSuite) FollowsWhen() {
+	var buf bytes.Buffer
+	var outside, inside, after *turns.Span
+	err := runManager(func(m *turns.Manager) {
+		m.SetSpanExporter(turns.NewSpanExporter(&buf, "test"))
+	}, func() async.R {
+		r := turns.InSpan("request", func() async.R {
+			turns.CurrentSpan().SetAttribute("id", 42)
+			return async.When(async.After(time.Millisecond), func() async.R {
+				inside = turns.CurrentSpan()
+				return turns.InSpan("lookup", func() async.R {
+					return async.Done()
+				})
+			})
+		})
+		outside = turns.CurrentSpan()
+		return async.When(r, func() {
+			after = turns.CurrentSpan()
+		})
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if outside != nil || after != nil {
+		t.Errorf("Expected no span outside InSpan.  Got: %v, %v, Want: nil", outside, after)
+	}
+	if (inside == nil) || (inside.Name() != "request") {
+		t.Errorf("Expected the span to follow When.  Got: %v, Want: request", inside)
+	}
+
+	spans, err := readSpans(&buf)
+	if err != nil {
+		t.Fatalf("Expected OTLP JSON.  Got: %v, Want: nil", err)
+	}
+	request, lookup := spans["request"], spans["lookup"]
+	if (request.SpanID == "") || (lookup.SpanID == "") {
+		t.Fatalf("Expected both spans to be exported.  Got: %+v, Want: request and lookup", spans)
+	}
+	if lookup.ParentSpanID != request.SpanID || lookup.TraceID != request.TraceID {
+		t.Errorf("Expected lookup to be a child.  Got: %+v, Want: parent %v", lookup, request.SpanID)
+	}
+	if request.ParentSpanID != "" {
+		t.Errorf("Expected a root span.  Got: %v, Want: none", request.ParentSpanID)
+	}
+	if len(request.Attributes) != 1 || request.Attributes[0].Value["intValue"] != "42" {
+		t.Errorf("Expected attribute.  Got: %+v, Want: id=42", request.Attributes)
+	}
+}
+
+// RecordsError verifies that a span records the failure of its operation.
+func (t *SpanSuite) RecordsError() {
+	var buf bytes.Buffer
+	runManager(func(m *turns.Manager) {
+		m.SetSpanExporter(turns.NewSpanExporter(&buf, "test"))
+	}, func() async.R {
+		return turns.InSpan("failing", func() async.R {
+			return async.NewError(errors.New("boom"))
+		})
+	})
+
+	spans, err := readSpans(&buf)
+	if err != nil {
+		t.Fatalf("Expected OTLP JSON.  Got: %v, Want: nil", err)
+	}
+	attrs := spans["failing"].Attributes
+	if len(attrs) != 1 || attrs[0].Key != "error" || attrs[0].Value["stringValue"] != "boom" {
+		t.Errorf("Expected error attribute.  Got: %+v, Want: error=boom", attrs)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the stepping API that allows a manager to be driven by an event loop that it
+// does not own (e.g. a UI main loop, a game loop or another framework's select loop).  Instead of
+// calling RunUntil, which blocks the calling goroutine until main is resolved, the host loop calls
+// Poll and RunTurns whenever it has time to spare and waits on Wake (and NextDeadline) when the
+// manager is Idle:
+//
+//   m := turns.NewManager(turns.NewUniqueIDGenerator())
+//   release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+//   defer release()
+//   ...
+//   for {
+//     m.Poll()
+//     m.RunTurns(100)
+//     if m.Idle() {
+//       select {
+//       case <-m.Wake():
+//       case <-hostEvents:
+//         ...
+//       }
+//     }
+//   }
+//
+// All stepping methods MUST be called on the goroutine that owns the manager (the one on which the
+// ambient runner was set).  The test modes that control I/O arrival (Replay and SetIOScheduler)
+// require RunUntil.
+
+import "time"
+
+// Poll moves expired timers and completed I/O onto the main queues without blocking and returns
+// the number of turns that are ready to run.  The number of I/O completions moved is limited by the
+// loop budget's MaxIOTurns.
+func (m *Manager) Poll() int {
+	m.poll(time.Now())
+	return m.length()
+}
+
+// RunTurns runs at most max of the turns that are ready to run and returns the number run.  If max
+// is zero or less then all turns that are ready at the time of the call are run.  Turns queued by
+// the turns that run are not run by this call unless max allows (in which case they run in the
+// usual priority order).
+func (m *Manager) RunTurns(max int) int {
+	if m.flight != nil {
+		defer m.flight.dumpOnPanic()
+	}
+	n := m.length()
+	if (max > 0) && (n > max) {
+		n = max
+	}
+	run := 0
+	for ; run < n; run++ {
+		t := m.next()
+		if t == nil {
+			break
+		}
+		m.run(t)
+		m.stats.Turns++
+	}
+	return run
+}
+
+// Idle returns true if there are no turns ready to run or waiting in the I/O backlog.  An idle
+// manager may still have work to do later when timers expire (see NextDeadline) or I/O completes
+// (see Wake).
+func (m *Manager) Idle() bool {
+	return m.isIdle()
+}
+
+// Wake returns a channel that receives a value when I/O has completed (or a FakeClock has been
+// advanced) and the manager should be polled.  Values are coalesced and may be stale: a receive
+// only indicates that Poll should be called.  Hosts that wait on a file descriptor rather than a
+// channel can forward receives to their own wake-up mechanism (e.g. by writing to a pipe).
+// THREADING: The returned channel may be used from any goroutine.
+func (m *Manager) Wake() <-chan struct{} {
+	return m.sources.Wake()
+}
+
+// NextDeadline returns the deadline of the earliest pending timer according to the manager's clock.
+// Returns false if there are no pending timers.  Hosts should Poll again no later than the
+// deadline.
+func (m *Manager) NextDeadline() (time.Time, bool) {
+	if len(m.timers) == 0 {
+		return time.Time{}, false
+	}
+	return m.timers[0].deadline, true
+}
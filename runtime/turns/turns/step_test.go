@@ -0,0 +1,121 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// StepSuite is the test suite for the stepping API.
+type StepSuite struct {
+	test.Suite
+}
+
+// TestStepSuite runs the test suite for the stepping API.
+func TestStepSuite(t *testing.T) {
+	test.RunSuite(t, new(StepSuite))
+}
+
+// HostLoop verifies that a host event loop can drive a manager to completion while servicing its
+// own events.
+func (t *StepSuite) HostLoop() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	unblock := make(chan struct{})
+	source := turns.NewNamedTurnSource("host")
+	defer source.Close()
+	done := false
+	async.When(source.New(func() error {
+		<-unblock
+		return nil
+	}), func() {
+		done = true
+	})
+
+	hostEvents := make(chan string, 1)
+	hostEvents <- "click"
+	handled := 0
+	for !done {
+		m.Poll()
+		m.RunTurns(0)
+		if done || !m.Idle() {
+			continue
+		}
+		select {
+		case <-m.Wake():
+		case <-hostEvents:
+			handled++
+			close(unblock)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Expected to be woken.  Got: timeout, Want: wake")
+		}
+	}
+	if handled != 1 {
+		t.Errorf("Expected host events to be serviced.  Got: %v, Want: %v", handled, 1)
+	}
+}
+
+// RunTurnsBounded verifies that RunTurns runs no more than the requested number of turns.
+func (t *StepSuite) RunTurnsBounded() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	count := 0
+	for i := 0; i < 5; i++ {
+		m.NewTurn("count", func() { count++ })
+	}
+	if n := m.Poll(); n != 5 {
+		t.Errorf("Expected ready turns.  Got: %v, Want: %v", n, 5)
+	}
+	if n := m.RunTurns(2); (n != 2) || (count != 2) {
+		t.Errorf("Expected two turns.  Got: %v (%v), Want: %v", n, count, 2)
+	}
+	if m.Idle() {
+		t.Errorf("Expected remaining turns.  Got: idle, Want: not idle")
+	}
+	if n := m.RunTurns(0); (n != 3) || !m.Idle() {
+		t.Errorf("Expected remaining turns.  Got: %v, Want: %v", n, 3)
+	}
+}
+
+// NextDeadline verifies that hosts can find when the next timer expires.
+func (t *StepSuite) NextDeadline() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	clock := turns.NewFakeClock(time.Unix(0, 0), true)
+	m.SetClock(clock)
+	if _, ok := m.NextDeadline(); ok {
+		t.Errorf("Expected no deadline.  Got: %v, Want: %v", ok, false)
+	}
+
+	fired := false
+	m.After(time.Minute, func() { fired = true })
+	deadline, ok := m.NextDeadline()
+	if !ok || !deadline.Equal(time.Unix(60, 0)) {
+		t.Errorf("Expected deadline.  Got: %v, Want: %v", deadline, time.Unix(60, 0))
+	}
+
+	clock.Advance(time.Minute)
+	m.Poll()
+	m.RunTurns(0)
+	if !fired {
+		t.Errorf("Expected the timer to fire.  Got: %v, Want: %v", fired, true)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the Manager's timers.  A timer is a turn that is queued once the manager's
+// clock reaches the timer's deadline.  Timers with the same deadline are queued in the order they
+// were created.  During replay timers ignore the clock and fire when the recording says they did
+// (see record.go).
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+)
+
+// timer is a turn waiting for its deadline.
+type timer struct {
+	// deadline is the time at which the turn is queued.
+	deadline time.Time
+
+	// seq orders timers with the same deadline.
+	seq uint64
+
+	// turn is queued when the timer fires.
+	turn *Turn
+}
+
+// timerHeap is a min-heap of timers ordered by deadline.
+type timerHeap []timer
+
+// Len implements heap.Interface.Len().
+func (h timerHeap) Len() int {
+	return len(h)
+}
+
+// Less implements heap.Interface.Less().
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+// Swap implements heap.Interface.Swap().
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// Push implements heap.Interface.Push().
+func (h *timerHeap) Push(x interface{}) {
+	*h = append(*h, x.(timer))
+}
+
+// Pop implements heap.Interface.Pop().
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// SetClock sets the clock used by the manager's timers.  The default clock is RealClock.
+// SetClock MUST be called before the manager starts running turns.
+func (m *Manager) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// watchClock registers the manager's clock as a source of I/O if it is a FakeClock that is advanced
+// explicitly.  The manager then waits for the clock to advance like any other source of I/O.  The
+// clock is only a source while timers are waiting for it so that a manager with nothing else to do
+// deadlocks rather than waiting forever for the clock.
+func (m *Manager) watchClock() {
+	fake, ok := m.clock.(*FakeClock)
+	if !ok || fake.auto || (m.clockSource != nil) || (m.replay != nil) {
+		return
+	}
+	m.clockSource = &turnSource{
+		manager: m,
+		name:    "clock",
+		label:   "clock",
+		lock:    sync.Mutex{},
+		list:    Empty,
+	}
+	m.clockSource.event = m.registerSource(m.clockSource)
+	fake.watch(m.clockSource.event)
+}
+
+// unwatchClock unregisters the manager's clock as a source of I/O (if it is one).
+func (m *Manager) unwatchClock() {
+	if m.clockSource == nil {
+		return
+	}
+	m.clock.(*FakeClock).unwatch(m.clockSource.event)
+	m.clockSource.Close()
+	m.clockSource = nil
+}
+
+// Now returns the current time on the manager's clock.
+func (m *Manager) Now() time.Time {
+	return m.clock.Now()
+}
+
+// After creates a new turn that will call f() once d has elapsed on the manager's clock.
+func (m *Manager) After(d time.Duration, f func()) {
+	// Watch the clock BEFORE reading it so that an advance is never missed.
+	m.watchClock()
+	m.timerSeq++
+	heap.Push(&m.timers, timer{
+		deadline: m.clock.Now().Add(d),
+		seq:      m.timerSeq,
+		turn:     m.allocTurn("Timer", m.NewID(), async.PriorityNormal, f),
+	})
+}
+
+// fireTimers queues the turns of all timers whose deadline has been reached.
+func (m *Manager) fireTimers() {
+	if (len(m.timers) == 0) || (m.replay != nil) {
+		return
+	}
+	now := m.clock.Now()
+	for (len(m.timers) > 0) && !m.timers[0].deadline.After(now) {
+		t := heap.Pop(&m.timers).(timer)
+		if m.recorder != nil {
+			m.recordTimer(t.seq)
+		}
+		m.Queue(t.turn)
+	}
+	if len(m.timers) == 0 {
+		m.unwatchClock()
+	}
+}
+
+// fireTimer queues the turn of the timer seq whatever its deadline.  Returns false if there is no
+// such timer waiting.
+func (m *Manager) fireTimer(seq uint64) bool {
+	for i, t := range m.timers {
+		if t.seq == seq {
+			heap.Remove(&m.timers, i)
+			m.Queue(t.turn)
+			return true
+		}
+	}
+	return false
+}
+
+// waitForTimer blocks until the next timer's deadline (or until I/O arrives).  Returns false if
+// there are no timers.  During replay timers fire from the recording so there is nothing to wait
+// for.
+// REQUIRES: the manager is idle.
+func (m *Manager) waitForTimer() bool {
+	if len(m.timers) == 0 {
+		return false
+	}
+	deadline := m.timers[0].deadline
+
+	fake, isFake := m.clock.(*FakeClock)
+	switch {
+	case isFake && fake.auto:
+		// Jump straight to the deadline.
+		fake.advanceTo(deadline)
+	case isFake:
+		// Wait for the clock to be advanced (or for other I/O).
+		if e := m.wait(); e != nil {
+			e.Signal() // force Select in next loop to see this source again.
+		}
+	case m.sources.Len() == 0:
+		m.sleep(deadline.Sub(m.clock.Now()))
+	default:
+		if e := m.waitTimeout(deadline.Sub(m.clock.Now())); e != nil {
+			e.Signal() // force Select in next loop to see this source again.
+		}
+	}
+	return true
+}
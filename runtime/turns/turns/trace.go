@@ -0,0 +1,322 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the tracing of turn execution.  A traced manager writes an event for every turn
+// it runs in the Chrome trace-event format (which is also understood by Perfetto), so that the
+// timeline of one or more actors can be visualized.  Each actor appears as a thread.  Each turn is a
+// slice annotated with its name, ID and source, and is connected by a flow arrow to the turn that
+// caused it: the turn that queued it (e.g. by resolving a result with a When continuation) or, for
+// the completion of I/O, the turn that started the I/O computation.  I/O that never arrives
+// (because its source was closed first, or the manager was closed) appears as an instant event
+// when it is found to be abandoned.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tracer writes trace events from one or more managers to a single trace.
+type Tracer struct {
+	// lock protects all fields below.
+	lock sync.Mutex
+
+	// w is the destination of the trace.
+	w io.Writer
+
+	// start is the time origin of the trace.
+	start time.Time
+
+	// pid is the process ID recorded in every event.
+	pid int
+
+	// threads is the number of managers that have been attached to the tracer.
+	threads int
+
+	// count is the number of events written.
+	count int
+
+	// err is the first error returned by w.
+	err error
+}
+
+// traceEvent is a single event in the Chrome trace-event format.
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	TS   float64                `json:"ts"`
+	Dur  float64                `json:"dur,omitempty"`
+	PID  int                    `json:"pid"`
+	TID  int                    `json:"tid"`
+	ID   string                 `json:"id,omitempty"`
+	BP   string                 `json:"bp,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// tracedTurn is what a traced manager knows about a turn before it runs.
+type tracedTurn struct {
+	// at is when the turn was queued, or when its I/O computation was started.
+	at time.Time
+
+	// cause is the run of the turn that queued this turn or started its I/O computation (or zero).
+	cause uint64
+
+	// source is the name of the I/O source the turn arrived on (or empty if not I/O).
+	source string
+
+	// io is the source on which the turn's I/O computation was started until the turn arrives (or
+	// nil).
+	io *turnSource
+}
+
+// NewTracer creates a tracer that writes a trace to w.  The trace is a JSON array of trace events.
+// The array is only terminated by Close, but trace viewers accept an unterminated array so a trace
+// remains readable if the process exits without closing the tracer.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{
+		w:     w,
+		start: time.Now(),
+		pid:   os.Getpid(),
+	}
+}
+
+// Close terminates the trace and returns the first error (if any) that occurred while writing it.
+// Managers MUST NOT run further turns using the tracer after Close.
+func (t *Tracer) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.count == 0 {
+		t.write([]byte("["))
+	}
+	t.write([]byte("]\n"))
+	return t.err
+}
+
+// write writes b unless an error has already occurred.
+// REQUIRES: t.lock is held.
+func (t *Tracer) write(b []byte) {
+	if t.err == nil {
+		_, t.err = t.w.Write(b)
+	}
+}
+
+// emit writes events to the trace.
+// THREADING: This method is multi-thread safe.
+func (t *Tracer) emit(events ...traceEvent) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.err = err
+			return
+		}
+		if t.count == 0 {
+			t.write([]byte("[\n"))
+		} else {
+			t.write([]byte(",\n"))
+		}
+		t.write(b)
+		t.count++
+	}
+}
+
+// timestamp converts a time to microseconds since the start of the trace.
+func (t *Tracer) timestamp(at time.Time) float64 {
+	return float64(at.Sub(t.start).Nanoseconds()) / 1e3
+}
+
+// attach allocates a thread for a manager named name and returns its thread ID.
+func (t *Tracer) attach(name string) int {
+	t.lock.Lock()
+	t.threads++
+	tid := t.threads
+	t.lock.Unlock()
+
+	if name == "" {
+		name = fmt.Sprintf("actor%d", tid)
+	}
+	t.emit(traceEvent{
+		Name: "thread_name",
+		Ph:   "M",
+		PID:  t.pid,
+		TID:  tid,
+		Args: map[string]interface{}{"name": name},
+	})
+	return tid
+}
+
+// SetTracer causes the manager to write an event to t for every turn that it runs.  The manager
+// appears in the trace as a thread with the given name.  Several managers (i.e. actors) may share a
+// tracer.  SetTracer MUST be called before the manager starts running turns.
+func (m *Manager) SetTracer(t *Tracer, name string) {
+	m.tracer = t
+	m.traceTID = t.attach(name)
+	m.traced = make(map[*Turn]tracedTurn)
+	m.tracePruneAt = minPruneAt
+	m.OnClose(m.abandonTraced)
+}
+
+// traceQueue records the cause of a turn that has been queued.  Turns that arrived from I/O keep
+// the cause recorded when their computation was started.
+func (m *Manager) traceQueue(t *Turn) {
+	if m.traced[t].source == "" {
+		m.traced[t] = tracedTurn{at: time.Now(), cause: m.running}
+	}
+}
+
+// traceStartIO records the cause of an I/O computation started on source whose completion will
+// run t.
+func (m *Manager) traceStartIO(t *Turn, source *turnSource) {
+	if len(m.traced) >= m.tracePruneAt {
+		m.pruneTraced()
+	}
+	info := tracedTurn{at: time.Now(), cause: m.running}
+	if m.replay == nil {
+		// During replay I/O arrives from the recording whether or not its source is still open.
+		info.io = source
+	}
+	m.traced[t] = info
+}
+
+// pruneTraced removes the I/O computations whose sources were closed before they arrived.  Pruning
+// is deferred until the set of traced turns has doubled in size so that its cost is amortized over
+// the I/O computations started.
+func (m *Manager) pruneTraced() {
+	for t, info := range m.traced {
+		if (info.io != nil) && info.io.event.IsClosed() && !info.io.holds(t) {
+			m.traceAbandon(t, info)
+		}
+	}
+	m.tracePruneAt = 2 * len(m.traced)
+	if m.tracePruneAt < minPruneAt {
+		m.tracePruneAt = minPruneAt
+	}
+}
+
+// abandonTraced abandons every I/O computation that has not arrived and forgets all other turns
+// when the manager is closed.
+func (m *Manager) abandonTraced() {
+	for t, info := range m.traced {
+		if info.io != nil {
+			m.traceAbandon(t, info)
+		}
+	}
+	m.traced = make(map[*Turn]tracedTurn)
+}
+
+// traceAbandon forgets the turn t of an I/O computation that will never arrive and writes an
+// instant event for it.
+func (m *Manager) traceAbandon(t *Turn, info tracedTurn) {
+	delete(m.traced, t)
+	args := map[string]interface{}{"source": info.io.name}
+	if info.cause != 0 {
+		args["cause"] = info.cause
+	}
+	m.tracer.emit(traceEvent{
+		Name: t.Name(),
+		Cat:  "abandoned",
+		Ph:   "i",
+		TS:   m.tracer.timestamp(time.Now()),
+		PID:  m.tracer.pid,
+		TID:  m.traceTID,
+		Args: args,
+	})
+}
+
+// traceIngest records the source of a list of turns that have arrived from I/O.
+func (m *Manager) traceIngest(source string, list *Turn) {
+	if list.IsEmpty() {
+		return
+	}
+	t := list.Peek()
+	for {
+		info, ok := m.traced[t]
+		if !ok {
+			info.at = time.Now()
+		}
+		info.source, info.io = source, nil
+		m.traced[t] = info
+		if t == list {
+			break
+		}
+		t = t.next
+	}
+}
+
+// traceRun runs t as the given run and writes its trace events.
+func (m *Manager) traceRun(t *Turn, run uint64) {
+	info := m.traced[t]
+	delete(m.traced, t)
+	name := t.Name()
+
+	start := time.Now()
+	t.Run()
+	end := time.Now()
+
+	tracer := m.tracer
+	args := map[string]interface{}{"run": run}
+	if !t.id.IsZero() {
+		args["id"] = t.id.String()
+	}
+	cat := "turn"
+	if info.source != "" {
+		cat = "io"
+		args["source"] = info.source
+	}
+	if info.cause != 0 {
+		args["cause"] = info.cause
+	}
+	events := []traceEvent{{
+		Name: name,
+		Cat:  cat,
+		Ph:   "X",
+		TS:   tracer.timestamp(start),
+		Dur:  tracer.timestamp(end) - tracer.timestamp(start),
+		PID:  tracer.pid,
+		TID:  m.traceTID,
+		Args: args,
+	}}
+
+	// The flow arrow starts inside the causing turn's slice and ends at the start of this turn.
+	if info.cause != 0 {
+		id := fmt.Sprintf("%d.%d", m.traceTID, run)
+		events = append(events, traceEvent{
+			Name: "cause",
+			Cat:  cat,
+			Ph:   "s",
+			TS:   tracer.timestamp(info.at),
+			PID:  tracer.pid,
+			TID:  m.traceTID,
+			ID:   id,
+		}, traceEvent{
+			Name: "cause",
+			Cat:  cat,
+			Ph:   "f",
+			BP:   "e",
+			TS:   tracer.timestamp(start),
+			PID:  tracer.pid,
+			TID:  m.traceTID,
+			ID:   id,
+		})
+	}
+	tracer.emit(events...)
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// TraceSuite is the test suite for turn tracing.
+type TraceSuite struct {
+	test.Suite
+}
+
+// TestTraceSuite runs the test suite for turn tracing.
+func TestTraceSuite(t *testing.T) {
+	test.RunSuite(t, new(TraceSuite))
+}
+
+// traceEvent is the subset of a trace event verified by the tests.
+type traceEvent struct {
+	Name string
+	Cat  string
+	Ph   string
+	TID  int
+	ID   string
+	Args map[string]interface{}
+}
+
+// Events verifies that turns, their sources and their causes are written to the trace.
+func (t *TraceSuite) Events() {
+	var buf bytes.Buffer
+	tracer := turns.NewTracer(&buf)
+	err := runManager(func(m *turns.Manager) {
+		m.SetTracer(tracer, "main")
+	}, func() async.R {
+		source := turns.NewNamedTurnSource("disk")
+		r := source.New(func() error { return nil })
+		return async.Finally(r, source.Close)
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("Expected trace to be written.  Got: %v, Want: nil", err)
+	}
+
+	var events []traceEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("Expected a JSON array.  Got: %v, Want: nil", err)
+	}
+
+	var thread, io bool
+	slices, starts, finishes := 0, map[string]bool{}, map[string]bool{}
+	for _, e := range events {
+		switch e.Ph {
+		case "M":
+			thread = thread || (e.Args["name"] == "main")
+		case "X":
+			slices++
+			if e.Cat == "io" {
+				io = (e.Args["source"] == "disk") && (e.Args["cause"] != nil)
+			}
+		case "s":
+			starts[e.ID] = true
+		case "f":
+			finishes[e.ID] = true
+		}
+	}
+	if !thread {
+		t.Errorf("Expected the thread to be named.  Got: %+v, Want: main", events)
+	}
+	if slices == 0 {
+		t.Errorf("Expected turns.  Got: %+v, Want: X events", events)
+	}
+	if !io {
+		t.Errorf("Expected a caused I/O turn from disk.  Got: %+v, Want: I/O turn", events)
+	}
+	if len(finishes) == 0 {
+		t.Errorf("Expected flow arrows.  Got: %+v, Want: flow events", events)
+	}
+	for id := range finishes {
+		if !starts[id] {
+			t.Errorf("Expected flow %v to start.  Got: %+v, Want: s event", id, events)
+		}
+	}
+}
+
+// Shared verifies that managers sharing a tracer appear as separate threads.
+func (t *TraceSuite) Shared() {
+	var buf bytes.Buffer
+	tracer := turns.NewTracer(&buf)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		runManager(func(m *turns.Manager) {
+			m.SetTracer(tracer, name)
+		}, func() async.R {
+			return async.Done()
+		})
+	}
+	tracer.Close()
+
+	var events []traceEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("Expected a JSON array.  Got: %v, Want: nil", err)
+	}
+	threads := map[int]bool{}
+	for _, e := range events {
+		if e.Ph == "X" {
+			threads[e.TID] = true
+		}
+	}
+	if len(threads) != 2 {
+		t.Errorf("Expected two threads.  Got: %v, Want: 2", threads)
+	}
+}
+
+// Abandoned verifies that I/O which never arrives, because its source or its manager was closed
+// first, is written to the trace once and then forgotten.
+func (t *TraceSuite) Abandoned() {
+	var buf bytes.Buffer
+	tracer := turns.NewTracer(&buf)
+	release := make(chan struct{})
+	defer close(release)
+	block := func() error {
+		<-release
+		return nil
+	}
+
+	// Enough closed sources to prune the traced turns, and one I/O still waiting at exit.
+	const closed = 40
+	var manager *turns.Manager
+	err := runManager(func(m *turns.Manager) {
+		manager = m
+		m.SetTracer(tracer, "main")
+	}, func() async.R {
+		for i := 0; i < closed; i++ {
+			source := turns.NewTurnSource()
+			source.New(block)
+			source.Close()
+		}
+		turns.NewNamedTurnSource("waiting").New(block)
+		return async.Done()
+	})
+	if err != nil {
+		t.Fatalf("Expected success.  Got: %v, Want: nil", err)
+	}
+	manager.Close()
+	tracer.Close()
+
+	var events []traceEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("Expected a JSON array.  Got: %v, Want: nil", err)
+	}
+	abandoned, waiting := 0, 0
+	for _, e := range events {
+		if e.Cat == "abandoned" {
+			abandoned++
+			if e.Args["source"] == "waiting" {
+				waiting++
+			}
+		}
+	}
+	if abandoned != closed+1 {
+		t.Errorf("Expected each I/O to be abandoned once.  Got: %v, Want: %v", abandoned, closed+1)
+	}
+	if waiting != 1 {
+		t.Errorf("Expected the waiting I/O to be abandoned at exit.  Got: %v, Want: 1", waiting)
+	}
+}
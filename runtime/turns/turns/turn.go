@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/prolang/drydock/runtime/base/assert"
+	"github.com/prolang/drydock/runtime/turns/async"
 
 	log "github.com/golang/glog"
 )
@@ -31,25 +32,57 @@ var Empty = &Turn{}
 // computation that are to be performed in FIFO order.  A turn may be in at most one turn list at a
 // time.
 //
-// All operations on turns are O(1) operations with the exception of Unlink() which may be O(n) in
-// the length of the list.
+// Lists are circular and doubly-linked through the turns themselves.  A list is referenced by its
+// tail so that both the head (tail.next) and tail are reachable in constant time.  All operations
+// on turns are O(1) operations.
 type Turn struct {
 	// f is the function to execute when running the turn.
 	f func()
 
-	// name is a diagnostic string used to identify the purpose of the turn.
-	name string
+	// label is a diagnostic string used to identify the purpose of the turn.
+	label string
+
+	// id (if non-zero) distinguishes the turn from others with the same label.  The turn's name is
+	// only formatted when it is needed for diagnostics.
+	id UniqueID
+
+	// pooled is true if the turn was allocated by a manager and is returned to the manager's free
+	// list after it has run.
+	pooled bool
+
+	// priority determines which of a manager's queues the turn is run from.
+	priority async.Priority
 
 	// next is the next turn if this turn is in a list, otherwise nil.
 	next *Turn
+
+	// prev is the previous turn if this turn is in a list, otherwise nil.
+	prev *Turn
+
+	// manager is the manager whose main queue holds the turn (if any).
+	manager *Manager
+
+	// cause identifies the turn that queued this turn.  Turns with the same cause are always run in
+	// the order they were queued.
+	cause uint64
+
+	// span is the span that was current when the turn was allocated and is current while it runs.
+	span *Span
 }
 
 // NewTurn creates a new single item turn with function f.
 func NewTurn(name string, f func()) *Turn {
+	return NewTurnWithPriority(name, async.PriorityNormal, f)
+}
+
+// NewTurnWithPriority creates a new single item turn with function f that runs at priority p.
+func NewTurnWithPriority(name string, p async.Priority, f func()) *Turn {
+	assert.True(p.IsValid(), "Invalid priority: %v", p)
 	return &Turn{
-		f:    f,
-		name: name,
-		next: nil,
+		f:        f,
+		label:    name,
+		priority: p,
+		next:     nil,
 	}
 }
 
@@ -62,19 +95,41 @@ func (list *Turn) Append(add *Turn) /*newList*/ *Turn {
 
 	log.V(3).Infof("%v: Append %v", list, add)
 	if list.IsEmpty() {
-		add.next = add // links to itself to complete the circle.
+		add.next, add.prev = add, add // links to itself to complete the circle.
 		return add
 	}
 
 	head := list.next
-	add.next = head
+	add.next, add.prev = head, list
+	head.prev = add
 	list.next = add
 	return add
 }
 
 // Name returns the diagnostic string for the turn.
 func (list *Turn) Name() string {
-	return list.name
+	if list.id.IsZero() {
+		return list.label
+	}
+	return list.label + list.id.String()
+}
+
+// Priority returns the priority at which the turn runs.
+func (list *Turn) Priority() async.Priority {
+	return list.priority
+}
+
+// count returns the number of turns in list.
+// Note: This is an O(n) operation in the length of the list.
+func (list *Turn) count() int {
+	if list.IsEmpty() {
+		return 0
+	}
+	n := 1
+	for t := list.next; t != list; t = t.next {
+		n++
+	}
+	return n
 }
 
 // IsEmpty returns true if list is the empty list.
@@ -94,7 +149,6 @@ func (list *Turn) Peek() *Turn {
 
 // Unlink removes a turn from a list and returns the new resulting list.
 // The turn may appear anywhere in the list including the middle.
-// Note: This is potentially an O(n) operation in the length of the list.
 // REQUIRES: the item MUST be either a single item or in the list.
 func (list *Turn) Unlink(t *Turn) /*newList*/ *Turn {
 	// If the item is not in any list, then unlinking is a no-op.
@@ -109,30 +163,16 @@ func (list *Turn) Unlink(t *Turn) /*newList*/ *Turn {
 	log.V(3).Infof("%v: Unlink %v", list, t)
 
 	// If the list contains only one item then it better be t.
-	if list == list.next {
+	if t.next == t {
 		assert.True(list == t, "Expected item %v to be in list %v.", t, list)
 
-		t.next = nil // unlink the item from the list.
+		t.next, t.prev = nil, nil // unlink the item from the list.
 		return Empty
 	}
 
-	// If t is the head then just remove it.
-	if list.next == t {
-		list.next = t.next
-		t.next = nil
-		return list
-	}
-
-	// Find the item (assuming it is in the list).
-	before := list.next
-	for before.next != t {
-		before = before.next
-		assert.True(before != list, "Expected item %v to be in list %v.", t, list)
-	}
-	assert.True(before.next == t, "Expected head %v to be t %v", before.next, t)
-
-	before.next = t.next
-	t.next = nil
+	before := t.prev
+	before.next, t.next.prev = t.next, before
+	t.next, t.prev = nil, nil
 
 	// If we removed the list item, the before is the new list, otherwise list hasn't changed.
 	if t == list {
@@ -151,11 +191,10 @@ func (list *Turn) RemoveHead() ( /*head*/ *Turn /*newList*/, *Turn) {
 
 	if list.next == list {
 		list = Empty
-		head.next = nil
 	} else {
-		list.next = head.next
-		head.next = nil
+		list.next, head.next.prev = head.next, list
 	}
+	head.next, head.prev = nil, nil
 
 	log.V(3).Infof("%v: RemoveHead %v", list, head)
 	return head, list
@@ -181,14 +220,14 @@ func (list *Turn) String() string {
 
 	// If the turn is not a list then just print the item.
 	if list.next == nil {
-		return fmt.Sprintf("<%s, %v, %p>", list.name, list.f, list.next)
+		return fmt.Sprintf("<%s, %p, %p>", list.Name(), list.f, list.next)
 	}
 
 	// If a list then print the whole list {head..tail}.
 	s := "{ "
 	for head := list.next; head != list; head = head.next {
-		s += fmt.Sprintf("{%s, %v, %p} ", head.name, head.f, head.next)
+		s += fmt.Sprintf("{%s, %p, %p} ", head.Name(), head.f, head.next)
 	}
-	s += fmt.Sprintf("{%s, %v, %p} }", list.name, list.f, list.next)
+	s += fmt.Sprintf("{%s, %p, %p} }", list.Name(), list.f, list.next)
 	return s
 }
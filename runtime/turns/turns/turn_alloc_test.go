@@ -0,0 +1,146 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"testing"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// runWorkload runs f on a fresh manager until its result is resolved.
+func runWorkload(b *testing.B, f func() async.R) {
+	runWorkloadWith(b, nil, f)
+}
+
+// runWorkloadWith is like runWorkload but calls configure (if not nil) with the manager first.
+func runWorkloadWith(b *testing.B, configure func(m *turns.Manager), f func() async.R) {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	if configure != nil {
+		configure(m)
+	}
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	r, s := async.NewR()
+	m.NewTurn("Workload", func() {
+		s.Forward(f())
+	})
+	if err := m.RunUntil(r); err != nil {
+		b.Fatalf("Expected workload to succeed.  Got: %v, Want: nil", err)
+	}
+}
+
+// whenChain returns a chain of count When turns each waiting on the previous one.
+func whenChain(count int) async.R {
+	r := async.Done()
+	for i := 0; i < count; i++ {
+		r = async.When(r, func() {})
+	}
+	return r
+}
+
+// newTurns returns a result that resolves after count turns have been created with New one at a
+// time.
+func newTurns(count int) async.R {
+	if count == 0 {
+		return async.Done()
+	}
+	return async.New(func() async.R {
+		return newTurns(count - 1)
+	})
+}
+
+// BenchmarkWhen measures the cost (and allocations) of a single When turn.
+func BenchmarkWhen(b *testing.B) {
+	b.ReportAllocs()
+	runWorkload(b, func() async.R {
+		return whenChain(b.N)
+	})
+}
+
+// BenchmarkNew measures the cost (and allocations) of a single New turn.
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	runWorkload(b, func() async.R {
+		return newTurns(b.N)
+	})
+}
+
+// BenchmarkMillionTurns measures a workload of a million turns in chains of a thousand Whens, both
+// with the manager's free list of turns (pooled) and without it (unpooled) to show the allocations
+// saved by pooling.
+func BenchmarkMillionTurns(b *testing.B) {
+	workload := func() async.R {
+		var last async.R
+		for j := 0; j < 1000; j++ {
+			last = whenChain(1000)
+		}
+		return last
+	}
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			runWorkload(b, workload)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			runWorkloadWith(b, (*turns.Manager).DisablePooling, workload)
+		}
+	})
+}
+
+// BenchmarkGetCurrentRunner measures the cost of looking up the ambient runner, which async.New,
+// async.NewR, async.Done and async.NewErrorf all do.
+func BenchmarkGetCurrentRunner(b *testing.B) {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+	defer release()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		async.GetCurrentRunner()
+	}
+}
+
+// BenchmarkNewR measures creating a result on the ambient runner (which is looked up on every call)
+// and on a runner passed explicitly.
+func BenchmarkNewR(b *testing.B) {
+	b.Run("ambient", func(b *testing.B) {
+		m := turns.NewManager(turns.NewUniqueIDGenerator())
+		release := async.SetAmbientRunner(turns.NewTurnRunner(m))
+		defer release()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			async.NewR()
+		}
+	})
+	b.Run("explicit", func(b *testing.B) {
+		runner := turns.NewTurnRunner(turns.NewManager(turns.NewUniqueIDGenerator()))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			async.NewROn(runner)
+		}
+	})
+}
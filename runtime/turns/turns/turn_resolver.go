@@ -17,6 +17,7 @@ package turns
 
 import (
 	"reflect"
+	"runtime"
 
 	"github.com/prolang/drydock/runtime/base/assert"
 	"github.com/prolang/drydock/runtime/turns/async"
@@ -36,16 +37,39 @@ type turnResolver struct {
 
 	// next pointer to another result this result has been forwarded to.
 	next *turnResolver
+
+	// waitPrev and waitNext link the resolver into its manager's list of unresolved results that
+	// have continuations.
+	waitPrev, waitNext *turnResolver
+
+	// sites (if not nil) is the stack at which the resolver was created.
+	sites *callSites
+
+	// live (if not nil) is the resolver's entry in its manager's registry of unresolved results.
+	live *liveResult
 }
 
 // newTurnResolver creates a new unresolved turn-based resolver.
+//
+// Unlike turns, resolvers are neither pooled nor allocated together: a resolver is referenced by
+// every ResultT derived from it and application code may retain and await those results at any
+// point in the future, so there is no point at which the manager knows a resolver is dead, and a
+// resolver allocated alongside others would keep them (and their outcomes) reachable for as long as
+// it is.  Allocating each resolver individually costs one allocation per result.
 func newTurnResolver(manager *Manager) *turnResolver {
-	return &turnResolver{
-		manager: manager,
-		turns:   Empty,
-		outcome: nil,
-		next:    nil,
+	if manager.metrics != nil {
+		manager.metrics.ResultsCreated.Add(1)
+	}
+	s := new(turnResolver)
+	s.manager, s.turns = manager, Empty
+	if manager.callSites {
+		s.sites = new(callSites)
+		runtime.Callers(2, s.sites[:])
+	}
+	if manager.trackResults {
+		s.addLive()
 	}
+	return s
 }
 
 // Complete implements Resolver.Complete().
@@ -79,7 +103,23 @@ func (s *turnResolver) Resolve(value interface{}, err error) {
 func (s *turnResolver) resolve(outcome interface{}) {
 	assert.True(!s.isResolved(), "Can't resolve an already resolved result.")
 
+	if s.manager.metrics != nil {
+		s.manager.metrics.ResultsResolved.Add(1)
+	}
+	if s.manager.flight != nil {
+		detail := ""
+		if err, ok := outcome.(error); ok {
+			detail = "failed: " + err.Error()
+		}
+		s.manager.flight.recordResult(flightResolve, detail)
+	}
 	turns := s.turns
+	if !turns.IsEmpty() {
+		s.removeWaiting()
+	}
+	if s.live != nil {
+		s.removeLive()
+	}
 	s.turns, s.outcome = nil, outcome
 	s.queueList(turns)
 }
@@ -91,8 +131,20 @@ func (s *turnResolver) Forward(n async.ResultT) {
 	next := async.InternalUseOnlyGetResolver(n).(*turnResolver)
 	assert.True(next.manager == s.manager, "Cannot forward across managers.")
 
+	if s.manager.metrics != nil {
+		s.manager.metrics.ResultsForwarded.Add(1)
+	}
+	if s.manager.flight != nil {
+		s.manager.flight.recordResult(flightForward, "")
+	}
 	next = next.getShortest()
 	turns := s.turns
+	if !turns.IsEmpty() {
+		s.removeWaiting()
+	}
+	if s.live != nil {
+		s.removeLive()
+	}
 	s.turns, s.outcome, s.next = nil, nil, next
 	next.queueList(turns)
 }
@@ -102,27 +154,39 @@ var (
 	reflectTypeInterface  = reflect.TypeOf((*interface{})(nil)).Elem()
 	reflectTypeError      = reflect.TypeOf((*error)(nil)).Elem()
 	reflectTypeAwaitableT = reflect.TypeOf((*async.AwaitableT)(nil)).Elem()
+	reflectTypeRunner     = reflect.TypeOf((*async.Runner)(nil)).Elem()
 )
 
+// Runner implements Resolver.Runner().
+func (s *turnResolver) Runner() async.Runner {
+	return NewTurnRunner(s.manager)
+}
+
 // When implements Resolver.WhenT().
 func (s *turnResolver) WhenT(in, out, outR reflect.Type, f interface{}) async.ResultT {
 	// Validate function type - this is in lieu of static type checking from generics.
 	fType := reflect.TypeOf(f)
 	assert.True(fType.Kind() == reflect.Func, "f MUST be a WhenFunc")
 
-	// Validate inputs - this is in lieu of static type checking from generics.
-	assert.True(fType.NumIn() <= 2, "f MUST take val, err, both or neither")
+	// Validate inputs - this is in lieu of static type checking from generics.  An optional leading
+	// Runner parameter receives the runner explicitly.
+	first := 0
+	takeRunner := (fType.NumIn() > 0) && (fType.In(0) == reflectTypeRunner)
+	if takeRunner {
+		first = 1
+	}
+	assert.True(fType.NumIn()-first <= 2, "f MUST take val, err, both or neither")
 	takeValue, takeError := true, true
-	if numIn := fType.NumIn(); numIn == 2 {
-		assert.True(in.AssignableTo(fType.In(0)), "in MUST be assignable to value")
-		assert.True(fType.In(1) == reflectTypeError, "f MUST take err")
+	if numIn := fType.NumIn() - first; numIn == 2 {
+		assert.True(in.AssignableTo(fType.In(first)), "in MUST be assignable to value")
+		assert.True(fType.In(first+1) == reflectTypeError, "f MUST take err")
 	} else if numIn == 1 {
-		takeError = (fType.In(0) == reflectTypeError)
+		takeError = (fType.In(first) == reflectTypeError)
 		takeValue = !takeError
 		if takeValue {
-			assert.True(in.AssignableTo(fType.In(0)), "in MUST be assignable to value")
+			assert.True(in.AssignableTo(fType.In(first)), "in MUST be assignable to value")
 		} else {
-			assert.True(fType.In(0) == reflectTypeError, "f MUST take err")
+			assert.True(fType.In(first) == reflectTypeError, "f MUST take err")
 		}
 	} else if numIn == 0 {
 		takeValue, takeError = false, false
@@ -154,7 +218,7 @@ func (s *turnResolver) WhenT(in, out, outR reflect.Type, f interface{}) async.Re
 
 	// Create a new turn that will run once the result is resolved.
 	outer := newTurnResolver(s.manager)
-	turn := NewTurn("When"+s.manager.NewID().String(), func() {
+	turn := s.manager.allocTurn("When", s.manager.NewID(), async.PriorityNormal, func() {
 		// Find the resolved value.
 		final := s.getShortest()
 		assert.True(final.isResolved(), "When's shouldn't run if the target is not resolved.")
@@ -178,7 +242,10 @@ func (s *turnResolver) WhenT(in, out, outR reflect.Type, f interface{}) async.Re
 		}
 
 		// Convert the arguments into an array of Values
-		args := make([]reflect.Value, 0, 2)
+		args := make([]reflect.Value, 0, 3)
+		if takeRunner {
+			args = append(args, reflect.ValueOf(s.Runner()))
+		}
 		if takeValue {
 			if value == nil {
 				args = append(args, reflect.New(in).Elem())
@@ -244,6 +311,9 @@ func (s *turnResolver) queue(turn *Turn) {
 	if s.isResolved() {
 		s.manager.Queue(turn)
 	} else {
+		if s.turns.IsEmpty() {
+			s.addWaiting()
+		}
 		s.turns = s.turns.Append(turn)
 	}
 }
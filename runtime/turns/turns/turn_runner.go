@@ -15,7 +15,11 @@
 
 package turns
 
-import "github.com/prolang/drydock/runtime/turns/async"
+import (
+	"time"
+
+	"github.com/prolang/drydock/runtime/turns/async"
+)
 
 // turnRunner is an implementation of async.Runner that uses turns to schedule asynchronous
 // computations and completions.
@@ -24,25 +28,38 @@ type turnRunner struct {
 	done    async.R
 }
 
-// NewTurnRunner creates a new runner that uses turns to schedule asynchronous
-// computations and completions.
+// NewTurnRunner returns the runner that uses manager's turns to schedule asynchronous
+// computations and completions.  Each manager has a single runner which is created on first use.
 func NewTurnRunner(manager *Manager) async.Runner {
-	return &turnRunner{
-		manager: manager,
-		done: async.R{async.NewResultT(&turnResolver{
+	if manager.runner == nil {
+		manager.runner = &turnRunner{
 			manager: manager,
-		})},
+			done: async.R{ResultT: async.NewResultT(&turnResolver{
+				manager: manager,
+			})},
+		}
 	}
+	return manager.runner
 }
 
 // New implements async.Runner.New().
 func (t *turnRunner) New(f async.Func) async.R {
-	r, s := async.NewR()
-	t.manager.NewTurn("New"+t.manager.NewID().String(), func() {
+	s := newTurnResolver(t.manager)
+	t.manager.Queue(t.manager.allocTurn("New", t.manager.NewID(), async.PriorityNormal, func() {
 		next := f()
-		s.Forward(next)
-	})
-	return r
+		s.Forward(next.ResultT)
+	}))
+	return async.R{ResultT: async.NewResultT(s)}
+}
+
+// NewWithPriority implements async.Runner.NewWithPriority().
+func (t *turnRunner) NewWithPriority(p async.Priority, f async.Func) async.R {
+	s := newTurnResolver(t.manager)
+	t.manager.Queue(t.manager.allocTurn("New", t.manager.NewID(), p, func() {
+		next := f()
+		s.Forward(next.ResultT)
+	}))
+	return async.R{ResultT: async.NewResultT(s)}
 }
 
 // NewResult implements async.Runner.NewResultT().
@@ -56,3 +73,17 @@ func (t *turnRunner) NewResultT() (async.ResultT, async.ResolverT) {
 func (t *turnRunner) Done() async.R {
 	return t.done
 }
+
+// Now implements async.Runner.Now().
+func (t *turnRunner) Now() time.Time {
+	return t.manager.Now()
+}
+
+// After implements async.Runner.After().
+func (t *turnRunner) After(d time.Duration) async.R {
+	s := newTurnResolver(t.manager)
+	t.manager.After(d, func() {
+		s.Complete(nil)
+	})
+	return async.R{ResultT: async.NewResultT(s)}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/prolang/drydock/runtime/turns/async"
 	"github.com/prolang/drydock/runtime/turns/test"
+	"github.com/prolang/drydock/runtime/turns/turns"
 )
 
 // TurnRunnerSuite is the test suite for Manager
@@ -131,3 +132,77 @@ func (t *TurnRunnerSuite) NewResultForwarded() async.R {
 		return nil
 	})
 }
+
+func (t *TurnRunnerSuite) NewWithPriority() async.R {
+	var order []async.Priority
+	record := func(p async.Priority) async.Func {
+		return func() async.R {
+			order = append(order, p)
+			return async.Done()
+		}
+	}
+	async.NewWithPriority(async.PriorityLow, record(async.PriorityLow))
+	async.NewWithPriority(async.PriorityNormal, record(async.PriorityNormal))
+	last := async.NewWithPriority(async.PriorityHigh, record(async.PriorityHigh))
+
+	return async.When(last, func() error {
+		if order[0] != async.PriorityHigh {
+			return fmt.Errorf("Expected high priority first.  Got: %v, Want: High", order)
+		}
+		return nil
+	})
+}
+
+// RunnerOf tests that a result knows the runner on which it was created.
+func (t *TurnRunnerSuite) RunnerOf() async.R {
+	current := async.GetCurrentRunner()
+	if got := async.RunnerOf(async.Done()); got != current {
+		t.Errorf("Expected Done's runner to be current.  Got: %v, Want: %v", got, current)
+	}
+	r, s := async.NewR()
+	s.Complete()
+	if got := async.RunnerOf(r); got != current {
+		t.Errorf("Expected NewR's runner to be current.  Got: %v, Want: %v", got, current)
+	}
+	return r
+}
+
+// WhenWithRunner tests that a When callback can take the runner as its first parameter.
+func (t *TurnRunnerSuite) WhenWithRunner() async.R {
+	current := async.GetCurrentRunner()
+	r := async.When(async.Done(), func(runner async.Runner) async.R {
+		if runner != current {
+			t.Errorf("Expected the current runner.  Got: %v, Want: %v", runner, current)
+		}
+		return runner.New(func() async.R {
+			return runner.Done()
+		})
+	})
+	return async.When(r, func(runner async.Runner, err error) error {
+		if runner != current {
+			t.Errorf("Expected the current runner.  Got: %v, Want: %v", runner, current)
+		}
+		return err
+	})
+}
+
+// AmbientNesting tests that nested ambient runners hide and then restore the previous runner.
+func (t *TurnRunnerSuite) AmbientNesting() {
+	idgen := turns.NewUniqueIDGenerator()
+	outer := turns.NewTurnRunner(turns.NewManager(idgen))
+	inner := turns.NewTurnRunner(turns.NewManager(idgen))
+
+	releaseOuter := async.SetAmbientRunner(outer)
+	releaseInner := async.SetAmbientRunner(inner)
+	if got := async.GetCurrentRunner(); got != inner {
+		t.Errorf("Expected inner runner.  Got: %v, Want: %v", got, inner)
+	}
+	releaseInner()
+	if got := async.GetCurrentRunner(); got != outer {
+		t.Errorf("Expected outer runner after release.  Got: %v, Want: %v", got, outer)
+	}
+	releaseOuter()
+	if async.IsCurrentRunner(outer) || async.IsCurrentRunner(inner) {
+		t.Errorf("Expected no ambient runner after release.  Got: current, Want: none")
+	}
+}
@@ -23,6 +23,7 @@ package turns
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/prolang/drydock/runtime/base/base"
 	"github.com/prolang/drydock/runtime/turns/async"
@@ -39,14 +40,28 @@ type turnSource struct {
 	// name is a diagnostic string used to identify the purpose of the turn.
 	name string
 
+	// label identifies the kind of source in the manager's metrics.  Unlike name it is not unique, so
+	// the number of distinct labels stays small.
+	label string
+
 	// event indicates when there are turns on this source that can be run.
 	event *base.Event
 
-	// lock protects list.
+	// lock protects list, completions and closed.
 	lock sync.Mutex
 
 	// list of turns to be executed on the main runner.
 	list *Turn
+
+	// completions are the outcomes of the I/O computations whose turns are in list, in the same
+	// order.  Only kept while the manager is recording or scheduling I/O.
+	completions []ioCompletion
+
+	// closed is true once the source has been closed.
+	closed bool
+
+	// daemon is true if the source does not keep the manager alive (see NewDaemonInbox).
+	daemon bool
 }
 
 // NewTurnSource creates a new source of I/O computations whose completions run on the ambient
@@ -56,6 +71,7 @@ func NewTurnSource() async.Source {
 	t := &turnSource{
 		manager: manager,
 		name:    "turnSource" + manager.NewID().String(),
+		label:   "turnSource",
 		lock:    sync.Mutex{},
 		list:    Empty,
 	}
@@ -65,7 +81,23 @@ func NewTurnSource() async.Source {
 }
 
 // Close implements async.Source.Close().
+// THREADING: This method is multi-thread safe.
 func (t *turnSource) Close() {
+	t.lock.Lock()
+	closed := t.closed
+	t.closed = true
+	t.lock.Unlock()
+	if closed {
+		return
+	}
+
+	if t.manager.metrics != nil {
+		t.manager.metrics.closeSource(t.label)
+	}
+	if !t.daemon {
+		// Only once the source is no longer counted as open may closing it wake the manager.
+		atomic.AddInt32(&t.manager.open, -1)
+	}
 	t.event.Close()
 }
 
@@ -73,39 +105,79 @@ func (t *turnSource) Close() {
 func (t *turnSource) New(f async.IOFunc) async.R {
 	// Allocate a resolver for the caller to use to track the completion of the I/O computation.
 	s := newTurnResolver(t.manager)
-	r := async.R{async.NewResultT(s)}
+	r := async.R{ResultT: async.NewResultT(s)}
 
 	// Pre-allocate a turn from our manager that will execute on the manager later when the I/O
 	// computation has completed.
 	// THREADING: this turn MUST be allocated here on the manager's thread because manager operations
 	// (e.g. NewID() are NOT multi-thread safe).
 	var err error
-	turn := NewTurn("IOResult"+t.manager.NewID().String(), func() {
+	seq := t.manager.nextIO()
+	turn := t.manager.allocTurn("IOResult", t.manager.NewID(), async.PriorityNormal, func() {
 		s.Resolve(nil, err)
 	})
+	if t.manager.tracer != nil {
+		t.manager.traceStartIO(turn, t)
+	}
+
+	// During replay the outcome comes from the recording and f is never executed.
+	if t.manager.replay != nil {
+		t.manager.replay.expect(seq, turn, &err)
+		return r
+	}
 
+	// Track the outcome of the computation if the manager needs it.
+	tracking := (t.manager.recorder != nil) || (t.manager.ioScheduler != nil)
+	if tracking {
+		t.manager.outstanding++
+	}
 	go func() {
 		// Execute the function on an I/O thread (separate from the turn manager).
 		err = f()
 
-		// Once it is finished atomically marshall the result to the I/O source.
+		// Once it is finished atomically marshall the result to the I/O source and signal the source
+		// that there is a turn available.  Signalling under the lock guarantees that a turn on the
+		// list is ingested even if the source is closed, while a computation that completes after
+		// the source is closed is dropped.
 		t.lock.Lock()
-		t.list = t.list.Append(turn)
+		if !t.closed {
+			t.list = t.list.Append(turn)
+			if tracking {
+				t.completions = append(t.completions, ioCompletion{seq: seq, err: err})
+			}
+			t.event.Signal()
+		}
 		t.lock.Unlock()
-
-		// Signal the source that there is a turn available.
-		t.event.Signal()
 	}()
 	return r
 }
 
-// getAllTurns atomically returns all turns that are ready to run (if any).
-func (t *turnSource) getAllTurns() /* list */ *Turn {
+// holds returns true if turn is waiting on the source to be ingested.
+// THREADING: This method is multi-thread safe.
+func (t *turnSource) holds(turn *Turn) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.list.IsEmpty() {
+		return false
+	}
+	for u := t.list.next; ; u = u.next {
+		if u == turn {
+			return true
+		}
+		if u == t.list {
+			return false
+		}
+	}
+}
+
+// getAllTurns atomically returns all turns that are ready to run (if any) and, if recording, the
+// outcomes of their I/O computations.
+func (t *turnSource) getAllTurns() ( /* list */ *Turn, []ioCompletion) {
 	t.lock.Lock()
-	retval := t.list
+	retval, completions := t.list, t.completions
 	log.V(3).Infof("getAllTurns: %s: %v", t.name, retval)
 
-	t.list = Empty
+	t.list, t.completions = Empty, nil
 	t.lock.Unlock()
-	return retval
+	return retval, completions
 }
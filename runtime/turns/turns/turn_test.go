@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/async"
 
 	log "github.com/golang/glog"
 )
@@ -313,3 +314,17 @@ func (t *TurnSuite) String() {
 		t.Errorf("Expected non-empty string representation: Got %s, Want: non-empty", s)
 	}
 }
+
+// PriorityIsValid verifies that only the defined priority levels are valid.
+func (t *TurnSuite) PriorityIsValid() {
+	for p := async.PriorityLow; int(p) < async.NumPriorities; p++ {
+		if !p.IsValid() {
+			t.Errorf("Expected %v to be valid.  Got: %v, Want: %v", p, false, true)
+		}
+	}
+	for _, p := range []async.Priority{-1, async.Priority(async.NumPriorities)} {
+		if p.IsValid() {
+			t.Errorf("Expected %v to be invalid.  Got: %v, Want: %v", p, true, false)
+		}
+	}
+}
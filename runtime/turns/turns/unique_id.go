@@ -17,7 +17,7 @@ package turns
 
 // This file contains an implementation of a thread-safe monotonically increasing ID generator.
 
-import "fmt"
+import "strconv"
 
 // UniqueID is an opaque unique identifier.
 type UniqueID struct {
@@ -26,7 +26,12 @@ type UniqueID struct {
 
 // String renders a unique identifier as a string for printing and logging.
 func (u UniqueID) String() string {
-	return fmt.Sprintf("%d", int64(u.id))
+	return strconv.FormatInt(u.id, 10)
+}
+
+// IsZero returns true if u is the zero UniqueID.  NewID never returns the zero UniqueID.
+func (u UniqueID) IsZero() bool {
+	return u.id == 0
 }
 
 // UniqueIDGenerator creates new uniqueIDs.
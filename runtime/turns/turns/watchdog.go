@@ -0,0 +1,244 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns
+
+// This file contains the watchdog which detects turns that run for too long.  Turns are expected
+// to be short and non-blocking.  A turn that accidentally performs blocking I/O or a long
+// computation stalls every other turn on its manager.  The watchdog runs on its own goroutine and
+// only observes the manager.  It never interrupts a turn.
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/assert"
+
+	log "github.com/golang/glog"
+)
+
+// SlowTurn describes a turn that has been running for longer than a watchdog's threshold.
+type SlowTurn struct {
+	// Label is the turn's label.
+	Label string
+
+	// ID is the turn's id.
+	ID UniqueID
+
+	// Duration is how long the turn had been running when it was detected.
+	Duration time.Duration
+
+	// Stack is the stack of the goroutine running the turn at the time it was detected.
+	Stack string
+}
+
+// String returns a multi-line description of the slow turn including its stack.
+func (s SlowTurn) String() string {
+	return fmt.Sprintf("turn %q (id %v) running for %v:\n%s", s.Label, s.ID, s.Duration, s.Stack)
+}
+
+// Watchdog detects turns that run for longer than a threshold.
+type Watchdog struct {
+	// threshold is the time a turn may run before it is reported.
+	threshold time.Duration
+
+	// report is called (on the watchdog's goroutine) for each slow turn.
+	report func(SlowTurn)
+
+	// manager is the manager whose turns are watched.  Its metrics (if enabled) count the slow turns.
+	manager *Manager
+
+	// stop is closed to stop the watchdog's goroutine.
+	stop chan struct{}
+
+	// stopOnce guards closing stop.
+	stopOnce sync.Once
+
+	// lock protects the fields below which are written on the manager's thread and read on the
+	// watchdog's goroutine.
+	lock sync.Mutex
+
+	// goroutine is the prefix of the stack trace header of the goroutine running the manager's turns.
+	goroutine string
+
+	// depth is the number of nested turns currently running.
+	depth int
+
+	// label, id and start describe the outermost running turn.
+	label string
+	id    UniqueID
+	start time.Time
+
+	// seq is incremented each time an outermost turn starts.
+	seq uint64
+
+	// reported is the seq of the last turn reported.
+	reported uint64
+}
+
+// StartWatchdog starts a watchdog that reports each turn that runs for longer than threshold.
+// report (if not nil) is called for each slow turn, otherwise the slow turn is logged as a warning.
+// report is called on the watchdog's goroutine (NOT the manager's thread) at most once per turn,
+// while the slow turn is still running.  If metrics are enabled then slow turns are also counted in
+// Metrics.SlowTurns.
+//
+// Turns are timed by the wall clock even if the manager uses a virtual clock (see SetClock).  The
+// watchdog is stopped when the manager is closed (or by calling Stop).
+// StartWatchdog MUST be called before the manager starts running turns.
+// REQUIRES: threshold is positive.
+func (m *Manager) StartWatchdog(threshold time.Duration, report func(SlowTurn)) *Watchdog {
+	assert.True(threshold > 0, "Watchdog threshold MUST be positive: %v", threshold)
+	if report == nil {
+		report = func(s SlowTurn) {
+			log.Warningf("Slow %v", s)
+		}
+	}
+	w := &Watchdog{
+		threshold: threshold,
+		report:    report,
+		manager:   m,
+		stop:      make(chan struct{}),
+	}
+	m.watchdog = w
+	m.OnClose(w.Stop)
+	go w.watch()
+	return w
+}
+
+// Watchdog returns the manager's watchdog, or nil if there is none.
+func (m *Manager) Watchdog() *Watchdog {
+	return m.watchdog
+}
+
+// Stop stops the watchdog.  A report already in progress when Stop is called may still complete.
+// THREADING: This method is multi-thread safe.
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		w.lock.Lock()
+		close(w.stop)
+		w.lock.Unlock()
+	})
+}
+
+// begin records that turn t has started running.
+func (w *Watchdog) begin(t *Turn) {
+	w.lock.Lock()
+	if w.goroutine == "" {
+		w.goroutine = goroutineHeader()
+	}
+	if w.depth == 0 {
+		w.label, w.id, w.start = t.label, t.id, time.Now()
+		w.seq++
+	}
+	w.depth++
+	w.lock.Unlock()
+}
+
+// end records that the most recently started turn has finished running.
+func (w *Watchdog) end() {
+	w.lock.Lock()
+	w.depth--
+	w.lock.Unlock()
+}
+
+// watch periodically checks the running turn until the watchdog is stopped.
+func (w *Watchdog) watch() {
+	interval := w.threshold / 4
+	if interval == 0 {
+		interval = w.threshold
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reports the running turn if it has exceeded the threshold and has not yet been reported.
+func (w *Watchdog) check() {
+	w.lock.Lock()
+	select {
+	case <-w.stop:
+		w.lock.Unlock()
+		return
+	default:
+	}
+	if (w.depth == 0) || (w.reported == w.seq) {
+		w.lock.Unlock()
+		return
+	}
+	d := time.Since(w.start)
+	if d < w.threshold {
+		w.lock.Unlock()
+		return
+	}
+	w.reported = w.seq
+	slow := SlowTurn{
+		Label:    w.label,
+		ID:       w.id,
+		Duration: d,
+	}
+	goroutine := w.goroutine
+
+	// Metrics are enabled before the manager runs any turns, so they are seen here once a turn has
+	// begun.
+	metrics := w.manager.metrics
+	w.lock.Unlock()
+
+	slow.Stack = goroutineStack(goroutine)
+	if metrics != nil {
+		metrics.SlowTurns.Add(1)
+	}
+	w.report(slow)
+}
+
+// goroutineHeader returns the prefix of the stack trace header of the calling goroutine (e.g.
+// "goroutine 42 ").
+func goroutineHeader() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	if i := bytes.IndexByte(buf, '['); i > 0 {
+		return string(buf[:i])
+	}
+	return string(buf)
+}
+
+// goroutineStack returns the stack trace of the goroutine whose header begins with header, or the
+// stacks of all goroutines if it cannot be found.
+func goroutineStack(header string) string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	for _, s := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(s, []byte(header)) {
+			return string(s)
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 The Drydock Authors.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+//
+
+package turns_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prolang/drydock/runtime/base/test"
+	"github.com/prolang/drydock/runtime/turns/turns"
+)
+
+// WatchdogSuite is the test suite for the watchdog.
+type WatchdogSuite struct {
+	test.Suite
+}
+
+// TestWatchdogSuite runs the test suite for the watchdog.
+func TestWatchdogSuite(t *testing.T) {
+	test.RunSuite(t, new(WatchdogSuite))
+}
+
+// blockingTurn sleeps long enough to be reported by a watchdog.
+func blockingTurn() {
+	time.Sleep(100 * time.Millisecond)
+}
+
+// SlowTurn verifies that a long running turn is reported exactly once with its stack.
+func (t *WatchdogSuite) SlowTurn() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	metrics := m.EnableMetrics()
+	reports := make(chan turns.SlowTurn, 10)
+	w := m.StartWatchdog(10*time.Millisecond, func(s turns.SlowTurn) {
+		reports <- s
+	})
+	defer w.Stop()
+
+	m.NewTurn("Fast", func() {})
+	m.NewTurn("Blocking", blockingTurn)
+	m.RunTurns(0)
+	w.Stop()
+
+	var got []turns.SlowTurn
+	for len(reports) > 0 {
+		got = append(got, <-reports)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Expected one slow turn.  Got: %v, Want: 1", len(got))
+	}
+	if got[0].Label != "Blocking" {
+		t.Errorf("Expected the blocking turn.  Got: %q, Want: %q", got[0].Label, "Blocking")
+	}
+	if got[0].Duration < 10*time.Millisecond {
+		t.Errorf("Expected duration over threshold.  Got: %v, Want: >= %v", got[0].Duration,
+			10*time.Millisecond)
+	}
+	if !strings.Contains(got[0].Stack, "blockingTurn") {
+		t.Errorf("Expected the turn's stack.  Got: %v, Want: blockingTurn", got[0].Stack)
+	}
+	if c := metrics.SlowTurns.Get(); c != 1 {
+		t.Errorf("Expected slow turn counted.  Got: %v, Want: %v", c, 1)
+	}
+}
+
+// NoSlowTurns verifies that turns under the threshold are not reported.
+func (t *WatchdogSuite) NoSlowTurns() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	reported := make(chan turns.SlowTurn, 1)
+	w := m.StartWatchdog(time.Second, func(s turns.SlowTurn) {
+		reported <- s
+	})
+	for i := 0; i < 100; i++ {
+		m.NewTurn("Fast", func() {})
+	}
+	m.RunTurns(0)
+	w.Stop()
+
+	if len(reported) != 0 {
+		t.Errorf("Expected no slow turns.  Got: %v, Want: none", <-reported)
+	}
+}
+
+// TinyThreshold verifies that a threshold too small to divide into ticks still works.
+func (t *WatchdogSuite) TinyThreshold() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	reports := make(chan turns.SlowTurn, 10)
+	w := m.StartWatchdog(time.Nanosecond, func(s turns.SlowTurn) {
+		reports <- s
+	})
+	m.NewTurn("Blocking", blockingTurn)
+	m.RunTurns(0)
+	w.Stop()
+
+	if len(reports) != 1 {
+		t.Errorf("Expected one slow turn.  Got: %v, Want: 1", len(reports))
+	}
+}
+
+// LateMetrics verifies that slow turns are counted by metrics enabled after the watchdog started.
+func (t *WatchdogSuite) LateMetrics() {
+	m := turns.NewManager(turns.NewUniqueIDGenerator())
+	reported := make(chan turns.SlowTurn, 1)
+	w := m.StartWatchdog(10*time.Millisecond, func(s turns.SlowTurn) {
+		reported <- s
+	})
+	metrics := m.EnableMetrics()
+	m.NewTurn("Blocking", blockingTurn)
+	m.RunTurns(0)
+	w.Stop()
+
+	if c := metrics.SlowTurns.Get(); c != 1 {
+		t.Errorf("Expected slow turn counted.  Got: %v, Want: %v", c, 1)
+	}
+}